@@ -65,13 +65,18 @@ func main() {
 	app.RedirectFixedPath = false
 
 	nftablesService := service.NewNftablesService()
-	mihomoService := service.NewMihomoService(cfg, configPath, nftablesService)
+	eventBus := service.NewEventBus()
+	mihomoService := service.NewMihomoService(cfg, configPath, nftablesService, eventBus)
 
 	if err := mihomoService.RestoreState(); err != nil {
 		log.Printf("Failed to restore mihomo state: %v", err)
 	}
 
-	router.Setup(app, mihomoService, nftablesService, cfg, configPath)
+	subscriptionService := service.NewSubscriptionService(cfg, configPath, mihomoService)
+	subscriptionService.Start()
+	defer subscriptionService.Stop()
+
+	router.Setup(app, mihomoService, nftablesService, subscriptionService, cfg, configPath)
 
 	if cfg.API.EnableSwagger {
 		app.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -135,9 +140,11 @@ func main() {
 	<-quit
 	log.Println("Shutting down server...")
 
-	if mihomoService.GetStatus() == "running" {
+	// A crashed mihomo waiting for its restart is stopped too, cancelling the
+	// restart and removing the routing rules.
+	if mihomoService.GetStatus() == "running" || mihomoService.Supervisor().NextRestart != nil {
 		log.Println("Stopping mihomo service...")
-		if err := mihomoService.Stop(false); err != nil {
+		if err := mihomoService.StopAndWait(false); err != nil {
 			log.Printf("Failed to stop mihomo: %v", err)
 		}
 	}
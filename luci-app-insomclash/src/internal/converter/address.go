@@ -0,0 +1,116 @@
+package converter
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// splitUserinfo splits "userinfo@host:port" at the last @. Userinfo may hold
+// an unescaped @ (passwords, emails as usernames) but a host never does.
+func splitUserinfo(authority string) (string, string, bool) {
+	idx := strings.LastIndex(authority, "@")
+	if idx == -1 {
+		return "", authority, false
+	}
+	return authority[:idx], authority[idx+1:], true
+}
+
+// splitEndpoint splits "host:port" into a normalized host and a numeric port.
+func splitEndpoint(endpoint string) (string, int, error) {
+	host, portStr, err := splitHostPortSpec(endpoint)
+	if err != nil {
+		return "", 0, err
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, parseError(ErrorBadPort, "invalid port: %v", err)
+	}
+
+	return host, port, nil
+}
+
+// splitHostPortSpec splits "host:port" where port is kept as text, as
+// hysteria2 allows port lists such as "443,8443-8450". It accepts bracketed
+// IPv6 literals, bare IPv6 literals with the port after the last colon,
+// percent-encoded hosts and IDN hostnames.
+func splitHostPortSpec(endpoint string) (string, string, error) {
+	endpoint = strings.TrimSuffix(strings.TrimSpace(endpoint), "/")
+
+	var host, port string
+	if strings.HasPrefix(endpoint, "[") {
+		idx := strings.LastIndex(endpoint, "]:")
+		if idx == -1 {
+			return "", "", parseError(ErrorBadAddress, "invalid server:port format")
+		}
+		host, port = endpoint[1:idx], endpoint[idx+2:]
+	} else {
+		idx := strings.LastIndex(endpoint, ":")
+		if idx == -1 {
+			return "", "", parseError(ErrorBadAddress, "invalid server:port format")
+		}
+		host, port = endpoint[:idx], endpoint[idx+1:]
+
+		// Anything but a bare IPv6 literal must not have more colons.
+		if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+			return "", "", parseError(ErrorBadAddress, "invalid server:port format")
+		}
+	}
+
+	if port == "" {
+		return "", "", parseError(ErrorBadPort, "missing port")
+	}
+
+	host, err := normalizeHost(host)
+	if err != nil {
+		return "", "", err
+	}
+
+	return host, port, nil
+}
+
+// normalizeHost unescapes a link host, strips IPv6 brackets and converts IDN
+// hostnames to their ASCII (punycode) form, which is what mihomo resolves.
+func normalizeHost(host string) (string, error) {
+	host = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(host), "["), "]")
+
+	if strings.Contains(host, "%") && net.ParseIP(strings.SplitN(host, "%", 2)[0]) == nil {
+		unescaped, err := url.PathUnescape(host)
+		if err != nil {
+			return "", parseError(ErrorBadAddress, "invalid server %q: %v", host, err)
+		}
+		host = unescaped
+	}
+
+	if host == "" {
+		return "", parseError(ErrorBadAddress, "missing server")
+	}
+
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+
+	if hasNonASCII(host) {
+		ascii, err := idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", parseError(ErrorBadAddress, "invalid server %q: %v", host, err)
+		}
+		host = ascii
+	}
+
+	return strings.TrimSuffix(host, "."), nil
+}
+
+func hasNonASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
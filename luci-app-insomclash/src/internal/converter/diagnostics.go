@@ -0,0 +1,364 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ErrorCategory tells why a link or proxy line could not be parsed.
+type ErrorCategory string
+
+const (
+	ErrorUnsupportedProtocol  ErrorCategory = "unsupported_protocol"
+	ErrorDecode               ErrorCategory = "decode_error"
+	ErrorMalformed            ErrorCategory = "malformed"
+	ErrorBadAddress           ErrorCategory = "bad_address"
+	ErrorBadPort              ErrorCategory = "bad_port"
+	ErrorMissingField         ErrorCategory = "missing_field"
+	ErrorUnsupportedTransport ErrorCategory = "unsupported_transport"
+	ErrorInvalid              ErrorCategory = "invalid"
+)
+
+// supportedNetworks are the transports mihomo can use for links.
+var supportedNetworks = map[string]bool{
+	"": true, "tcp": true, "ws": true, "websocket": true, "httpupgrade": true,
+	"grpc": true, "h2": true, "http": true, "xhttp": true, "splithttp": true,
+}
+
+// ParseError is returned by the link and line parsers with the category of
+// the failure.
+type ParseError struct {
+	Category ErrorCategory
+	Err      error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func parseError(category ErrorCategory, format string, args ...interface{}) error {
+	return &ParseError{Category: category, Err: fmt.Errorf(format, args...)}
+}
+
+// ErrorCategoryOf returns the category of a parse error, or ErrorInvalid when
+// err carries none.
+func ErrorCategoryOf(err error) ErrorCategory {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.Category
+	}
+	return ErrorInvalid
+}
+
+// LinkResult is the outcome of parsing one link or proxy line. Index is the
+// position of the line in the input, starting at 0. Link has credentials
+// replaced with "***".
+type LinkResult struct {
+	Index    int           `json:"index"`
+	Link     string        `json:"link"`
+	Type     string        `json:"type,omitempty" example:"vless"`
+	Name     string        `json:"name,omitempty"`
+	Category ErrorCategory `json:"category,omitempty" example:"bad_port"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// ProtocolSummary counts the parsed and failed entries of one proxy type.
+type ProtocolSummary struct {
+	Total  int `json:"total"`
+	Parsed int `json:"parsed"`
+	Failed int `json:"failed"`
+}
+
+// ParseReport describes how the entries of a subscription were parsed.
+// Results are only filled for line based formats (links, Surge and
+// Quantumult X); structured formats only report the counts.
+type ParseReport struct {
+	Total     int                         `json:"total"`
+	Parsed    int                         `json:"parsed"`
+	Failed    int                         `json:"failed"`
+	Protocols map[string]*ProtocolSummary `json:"protocols"`
+	Results   []LinkResult                `json:"results,omitempty"`
+}
+
+func newParseReport() *ParseReport {
+	return &ParseReport{Protocols: make(map[string]*ProtocolSummary)}
+}
+
+// summarizeProxies builds a report without per-line results.
+func summarizeProxies(proxies []*Proxy) *ParseReport {
+	report := newParseReport()
+	for _, proxy := range proxies {
+		report.count(string(proxy.Type), true)
+	}
+	return report
+}
+
+func (r *ParseReport) count(typ string, parsed bool) {
+	if typ == "" {
+		typ = "unknown"
+	}
+
+	summary := r.Protocols[typ]
+	if summary == nil {
+		summary = &ProtocolSummary{}
+		r.Protocols[typ] = summary
+	}
+
+	r.Total++
+	summary.Total++
+	if parsed {
+		r.Parsed++
+		summary.Parsed++
+	} else {
+		r.Failed++
+		summary.Failed++
+	}
+}
+
+// add records the result of parsing line as either proxy or err.
+func (r *ParseReport) add(index int, line, typ string, proxy *Proxy, err error) {
+	result := LinkResult{
+		Index: index,
+		Link:  RedactLink(line),
+		Type:  typ,
+	}
+
+	if err != nil {
+		result.Category = ErrorCategoryOf(err)
+		result.Error = err.Error()
+	} else {
+		result.Type = string(proxy.Type)
+		result.Name = proxy.Name
+	}
+
+	r.count(result.Type, err == nil)
+	r.Results = append(r.Results, result)
+}
+
+// err summarises the failures once nothing could be parsed.
+func (r *ParseReport) err(what string) error {
+	var messages []string
+	for _, result := range r.Results {
+		if result.Error != "" {
+			messages = append(messages, result.Error)
+		}
+	}
+	return fmt.Errorf("failed to parse any %s: %v", what, messages)
+}
+
+// protocolNames returns the reported proxy types in a stable order.
+func (r *ParseReport) protocolNames() []string {
+	names := make([]string, 0, len(r.Protocols))
+	for name := range r.Protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String gives a one line summary such as "18/20 parsed (vless 10/10, ss 8/10)".
+func (r *ParseReport) String() string {
+	var parts []string
+	for _, name := range r.protocolNames() {
+		summary := r.Protocols[name]
+		parts = append(parts, fmt.Sprintf("%s %d/%d", name, summary.Parsed, summary.Total))
+	}
+	return fmt.Sprintf("%d/%d parsed (%s)", r.Parsed, r.Total, strings.Join(parts, ", "))
+}
+
+// checkProxy rejects parsed proxies mihomo would refuse to load.
+func checkProxy(proxy *Proxy) error {
+	if proxy.Port < 1 || proxy.Port > 65535 {
+		return parseError(ErrorBadPort, "invalid port: %d out of range", proxy.Port)
+	}
+	if !supportedNetworks[strings.ToLower(proxy.Network)] {
+		return parseError(ErrorUnsupportedTransport, "unsupported transport: %s", proxy.Network)
+	}
+	return nil
+}
+
+// LinkType returns the proxy type of a share link from its scheme, or an
+// empty string.
+func LinkType(link string) string {
+	link = strings.TrimSpace(link)
+
+	switch {
+	case strings.HasPrefix(link, "vmess://"):
+		return string(ProxyTypeVMess)
+	case strings.HasPrefix(link, "vless://"):
+		return string(ProxyTypeVLess)
+	case strings.HasPrefix(link, "trojan://"):
+		return string(ProxyTypeTrojan)
+	case strings.HasPrefix(link, "ss://"):
+		return string(ProxyTypeSS)
+	case strings.HasPrefix(link, "ssr://"):
+		return string(ProxyTypeSSR)
+	case strings.HasPrefix(link, "hysteria2://"), strings.HasPrefix(link, "hy2://"):
+		return string(ProxyTypeHysteria2)
+	case strings.HasPrefix(link, "tuic://"):
+		return string(ProxyTypeTUIC)
+	case strings.HasPrefix(link, "wireguard://"), strings.HasPrefix(link, "wg://"):
+		return string(ProxyTypeWireGuard)
+	case strings.HasPrefix(link, "socks"):
+		return string(ProxyTypeSocks5)
+	case IsHTTPProxyLink(link):
+		return string(ProxyTypeHTTP)
+	}
+	return ""
+}
+
+// RedactLink hides passwords, UUIDs and keys in a share link or a Surge /
+// Quantumult X proxy line so it can be shown or logged.
+func RedactLink(link string) string {
+	link = strings.TrimSpace(link)
+
+	switch {
+	case strings.HasPrefix(link, "vmess://"):
+		return redactVMess(link)
+	case strings.HasPrefix(link, "ssr://"):
+		return redactSSR(link)
+	case strings.Contains(link, "://"):
+		return redactURL(link)
+	case IsQuantumultXLine(link):
+		return redactFields(link, 1)
+	case IsSurgeLine(link):
+		return redactFields(link, 3)
+	}
+	return link
+}
+
+func redactVMess(link string) string {
+	decoded, err := decodeBase64(strings.TrimPrefix(link, "vmess://"))
+	if err != nil {
+		return "vmess://***"
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(decoded, &config); err != nil {
+		return "vmess://***"
+	}
+	if _, ok := config["id"]; ok {
+		config["id"] = "***"
+	}
+
+	data, _ := json.Marshal(config)
+	return "vmess://" + string(data)
+}
+
+// redactSSR hides the password, the last field of
+// server:port:protocol:method:obfs:password.
+func redactSSR(link string) string {
+	decoded, err := decodeBase64(strings.TrimPrefix(link, "ssr://"))
+	if err != nil {
+		return "ssr://***"
+	}
+
+	main, params := string(decoded), ""
+	if idx := strings.Index(main, "/?"); idx != -1 {
+		main, params = main[:idx], main[idx:]
+	}
+
+	fields := strings.Split(main, ":")
+	if len(fields) < 6 {
+		return "ssr://***"
+	}
+	fields[len(fields)-1] = "***"
+	return "ssr://" + strings.Join(fields, ":") + params
+}
+
+func redactURL(link string) string {
+	var fragment string
+	if idx := strings.Index(link, "#"); idx != -1 {
+		link, fragment = link[:idx], link[idx:]
+	}
+
+	var query string
+	if idx := strings.Index(link, "?"); idx != -1 {
+		link, query = link[:idx], link[idx+1:]
+	}
+
+	idx := strings.Index(link, "://")
+	scheme, rest := link[:idx+3], link[idx+3:]
+
+	if at := strings.LastIndex(rest, "@"); at != -1 {
+		rest = "***@" + rest[at+1:]
+	} else if strings.HasPrefix(scheme, "ss://") {
+		// Legacy ss links encode method:password@server:port as a whole.
+		rest = "***"
+	}
+
+	if query != "" {
+		params := strings.Split(query, "&")
+		for i, param := range params {
+			kv := strings.SplitN(param, "=", 2)
+			key, _ := url.QueryUnescape(kv[0])
+			if len(kv) == 2 && isSecretKey(key) {
+				params[i] = kv[0] + "=***"
+			} else if len(kv) == 2 && key == "plugin" {
+				params[i] = kv[0] + "=" + redactPluginOpts(kv[1])
+			}
+		}
+		rest += "?" + strings.Join(params, "&")
+	}
+
+	return scheme + rest + fragment
+}
+
+// redactPluginOpts hides secret options in an ss plugin parameter such as
+// "shadow-tls;host=example.com;password=secret". The result is left
+// unescaped so it stays readable.
+func redactPluginOpts(plugin string) string {
+	if unescaped, err := url.QueryUnescape(plugin); err == nil {
+		plugin = unescaped
+	}
+
+	opts := strings.Split(plugin, ";")
+	for i := 1; i < len(opts); i++ {
+		kv := strings.SplitN(opts[i], "=", 2)
+		if len(kv) == 2 && isSecretKey(kv[0]) {
+			opts[i] = kv[0] + "=***"
+		}
+	}
+	return strings.Join(opts, ";")
+}
+
+// redactFields hides the values of secret key=value fields and any
+// positional credentials after the first keep comma separated fields.
+func redactFields(line string, keep int) string {
+	fields := strings.Split(line, ",")
+	for i := keep; i < len(fields); i++ {
+		kv := strings.SplitN(fields[i], "=", 2)
+		if len(kv) != 2 {
+			fields[i] = " ***"
+			continue
+		}
+		if isSecretKey(strings.TrimSpace(kv[0])) {
+			fields[i] = kv[0] + "=***"
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+
+	switch key {
+	case "id", "uuid", "username", "user", "key", "privatekey", "private-key", "private_key":
+		return true
+	}
+
+	for _, part := range []string{"pass", "psk", "presharedkey", "pre-shared-key", "auth", "token", "secret"} {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,421 @@
+package converter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func EncodeLinks(proxies []*Proxy) ([]string, []error) {
+	var links []string
+	var errors []error
+
+	for _, proxy := range proxies {
+		link, err := proxy.ToLink()
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		links = append(links, link)
+	}
+
+	return links, errors
+}
+
+func EncodeSubscription(links []string) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Join(links, "\n")))
+}
+
+// ToLink encodes the proxy back into the share link format read by ParseLink.
+func (p *Proxy) ToLink() (string, error) {
+	switch p.Type {
+	case ProxyTypeVMess:
+		return p.vmessLink()
+	case ProxyTypeVLess:
+		return p.uriLink("vless", escapeComponent(p.UUID), p.vlessParams()), nil
+	case ProxyTypeTrojan:
+		return p.uriLink("trojan", escapeComponent(p.Password), p.trojanParams()), nil
+	case ProxyTypeSS:
+		return p.ssLink(), nil
+	case ProxyTypeSSR:
+		return p.ssrLink(), nil
+	case ProxyTypeHysteria2:
+		return p.hysteria2Link(), nil
+	case ProxyTypeTUIC:
+		return p.uriLink("tuic", escapeComponent(p.UUID)+":"+escapeComponent(p.Password), p.tuicParams()), nil
+	case ProxyTypeWireGuard:
+		return p.wireGuardLink()
+	case ProxyTypeSocks5:
+		scheme := "socks5"
+		if p.TLS {
+			scheme = "socks5+tls"
+		}
+		return p.uriLink(scheme, p.authUserinfo(), p.proxyTLSParams()), nil
+	case ProxyTypeHTTP:
+		scheme := "http"
+		if p.TLS {
+			scheme = "https"
+		}
+		return p.uriLink(scheme, p.authUserinfo(), p.proxyTLSParams()), nil
+	}
+
+	return "", fmt.Errorf("cannot encode proxy %q: unsupported type %q", p.Name, p.Type)
+}
+
+func (p *Proxy) uriLink(scheme string, userinfo string, params url.Values) string {
+	var b strings.Builder
+	b.WriteString(scheme)
+	b.WriteString("://")
+	if userinfo != "" {
+		b.WriteString(userinfo)
+		b.WriteString("@")
+	}
+	b.WriteString(net.JoinHostPort(p.Server, strconv.Itoa(p.Port)))
+	if len(params) > 0 {
+		b.WriteString("?")
+		b.WriteString(params.Encode())
+	}
+	if p.Name != "" {
+		b.WriteString("#")
+		b.WriteString(escapeComponent(p.Name))
+	}
+	return b.String()
+}
+
+func (p *Proxy) vmessLink() (string, error) {
+	config := vmessConfig{
+		V:    "2",
+		PS:   p.Name,
+		Add:  p.Server,
+		Port: strconv.Itoa(p.Port),
+		ID:   p.UUID,
+		Aid:  strconv.Itoa(p.AlterId),
+		SCY:  p.Cipher,
+		Net:  p.Network,
+		Type: "none",
+		SNI:  p.SNI,
+		FP:   p.ClientFingerprint,
+	}
+
+	switch config.Net {
+	case "":
+		config.Net = "tcp"
+	case "http":
+		config.Net, config.Type = "tcp", "http"
+	case "xhttp":
+		if p.XHTTPMode != "" {
+			config.Type = p.XHTTPMode
+		}
+	}
+
+	if p.TLS {
+		config.TLS = "tls"
+	}
+
+	if len(p.ALPN) > 0 {
+		config.ALPN = strings.Join(p.ALPN, ",")
+	}
+
+	config.Host, config.Path = p.transportHostPath()
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode vmess config: %v", err)
+	}
+
+	return "vmess://" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// transportHostPath returns the host and path (or gRPC service name) that
+// share links carry for the proxy's transport.
+func (p *Proxy) transportHostPath() (string, string) {
+	host := p.WSHeaders["Host"]
+
+	switch p.Network {
+	case "ws", "websocket":
+		return host, p.WSPath
+	case "grpc":
+		return host, p.GRPCServiceName
+	case "splithttp":
+		return host, p.SplitHTTPPath
+	case "xhttp":
+		return host, p.XHTTPPath
+	case "httpupgrade":
+		return host, p.HTTPUpgradePath
+	case "h2":
+		return p.h2HostPath()
+	case "http":
+		return p.httpHostPath()
+	}
+
+	return host, ""
+}
+
+func (p *Proxy) setTransportParams(params url.Values) {
+	switch p.Network {
+	case "", "tcp":
+		params.Set("type", "tcp")
+		return
+	case "h2":
+		params.Set("type", "http")
+	case "http":
+		params.Set("type", "tcp")
+		params.Set("headerType", "http")
+	default:
+		params.Set("type", p.Network)
+	}
+
+	host, path := p.transportHostPath()
+	if host != "" {
+		params.Set("host", host)
+	}
+	if path != "" {
+		if p.Network == "grpc" {
+			params.Set("serviceName", path)
+		} else {
+			params.Set("path", path)
+		}
+	}
+
+	if p.Network == "xhttp" {
+		if p.XHTTPMode != "" {
+			params.Set("mode", p.XHTTPMode)
+		}
+		if len(p.XHTTPExtra) > 0 {
+			if extra, err := json.Marshal(p.XHTTPExtra); err == nil {
+				params.Set("extra", string(extra))
+			}
+		}
+	}
+}
+
+func (p *Proxy) setTLSParams(params url.Values) {
+	switch {
+	case p.RealityOpts != nil:
+		params.Set("security", "reality")
+		params.Set("pbk", p.RealityOpts.PublicKey)
+		if p.RealityOpts.ShortID != "" {
+			params.Set("sid", p.RealityOpts.ShortID)
+		}
+		if p.RealityOpts.SpiderX != "" {
+			params.Set("spx", p.RealityOpts.SpiderX)
+		}
+	case p.TLS || p.Type == ProxyTypeTrojan:
+		// Trojan always runs over TLS; clients read "none" as plaintext.
+		params.Set("security", "tls")
+	default:
+		params.Set("security", "none")
+	}
+
+	if p.SNI != "" {
+		params.Set("sni", p.SNI)
+	}
+	if len(p.ALPN) > 0 {
+		params.Set("alpn", strings.Join(p.ALPN, ","))
+	}
+	if p.ClientFingerprint != "" {
+		params.Set("fp", p.ClientFingerprint)
+	}
+	if p.SkipCertVerify {
+		params.Set("allowInsecure", "1")
+	}
+}
+
+func (p *Proxy) vlessParams() url.Values {
+	params := url.Values{}
+	params.Set("encryption", "none")
+	p.setTLSParams(params)
+	p.setTransportParams(params)
+	if p.Flow != "" {
+		params.Set("flow", p.Flow)
+	}
+	return params
+}
+
+func (p *Proxy) trojanParams() url.Values {
+	params := url.Values{}
+	p.setTLSParams(params)
+	p.setTransportParams(params)
+	return params
+}
+
+func (p *Proxy) ssLink() string {
+	userinfo := base64.RawURLEncoding.EncodeToString([]byte(p.Cipher + ":" + p.Password))
+
+	var params url.Values
+	if p.Plugin != "" {
+		params = url.Values{"plugin": {p.sip002Plugin()}}
+	}
+
+	return p.uriLink("ss", userinfo, params)
+}
+
+func (p *Proxy) ssrLink() string {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	main := strings.Join([]string{
+		p.Server,
+		strconv.Itoa(p.Port),
+		p.Protocol,
+		p.Cipher,
+		p.Obfs,
+		encode(p.Password),
+	}, ":")
+
+	params := url.Values{}
+	params.Set("obfsparam", encode(p.ObfsParam))
+	params.Set("protoparam", encode(p.ProtocolParam))
+	params.Set("remarks", encode(p.Name))
+
+	return "ssr://" + encode(main+"/?"+params.Encode())
+}
+
+func (p *Proxy) hysteria2Link() string {
+	params := url.Values{}
+	if p.SNI != "" {
+		params.Set("sni", p.SNI)
+	}
+	if p.Obfs != "" {
+		params.Set("obfs", p.Obfs)
+		params.Set("obfs-password", p.ObfsPassword)
+	}
+	if p.SkipCertVerify {
+		params.Set("insecure", "1")
+	}
+	if p.Fingerprint != "" {
+		params.Set("pinSHA256", p.Fingerprint)
+	}
+	if p.Ports != "" {
+		params.Set("mport", p.Ports)
+	}
+	if len(p.ALPN) > 0 {
+		params.Set("alpn", strings.Join(p.ALPN, ","))
+	}
+	if p.Up != "" {
+		params.Set("up", p.Up)
+	}
+	if p.Down != "" {
+		params.Set("down", p.Down)
+	}
+
+	return p.uriLink("hysteria2", escapeComponent(p.Password), params)
+}
+
+func (p *Proxy) tuicParams() url.Values {
+	params := url.Values{}
+	if p.CongestionControl != "" {
+		params.Set("congestion_control", p.CongestionControl)
+	}
+	if p.UDPRelayMode != "" {
+		params.Set("udp_relay_mode", p.UDPRelayMode)
+	}
+	if len(p.ALPN) > 0 {
+		params.Set("alpn", strings.Join(p.ALPN, ","))
+	}
+	if p.SNI != "" {
+		params.Set("sni", p.SNI)
+	}
+	if p.SkipCertVerify {
+		params.Set("allow_insecure", "1")
+	}
+	if p.DisableSNI {
+		params.Set("disable_sni", "1")
+	}
+	if p.ReduceRTT {
+		params.Set("reduce_rtt", "1")
+	}
+	return params
+}
+
+// wireGuardLink encodes a wireguard proxy. A link holds a single peer, so a
+// proxy with several peers cannot be exported without losing them.
+func (p *Proxy) wireGuardLink() (string, error) {
+	if len(p.Peers) > 1 {
+		return "", fmt.Errorf("cannot encode proxy %q: wireguard links hold one peer, it has %d", p.Name, len(p.Peers))
+	}
+
+	proxy := *p
+	if len(p.Peers) == 1 {
+		peer := p.Peers[0]
+		proxy.Server = peer.Server
+		proxy.Port = peer.Port
+		proxy.PublicKey = peer.PublicKey
+		proxy.PreSharedKey = peer.PreSharedKey
+		proxy.AllowedIPs = peer.AllowedIPs
+		proxy.Reserved = peer.Reserved
+	}
+	return proxy.uriLink("wireguard", escapeComponent(proxy.PrivateKey), proxy.wireGuardParams()), nil
+}
+
+func (p *Proxy) wireGuardParams() url.Values {
+	params := url.Values{}
+	params.Set("publickey", p.PublicKey)
+	if p.PreSharedKey != "" {
+		params.Set("presharedkey", p.PreSharedKey)
+	}
+
+	var addresses []string
+	if p.IP != "" {
+		addresses = append(addresses, withPrefix(p.IP, "/32"))
+	}
+	if p.IPv6 != "" {
+		addresses = append(addresses, withPrefix(p.IPv6, "/128"))
+	}
+	if len(addresses) > 0 {
+		params.Set("address", strings.Join(addresses, ","))
+	}
+
+	if len(p.AllowedIPs) > 0 {
+		params.Set("allowedips", strings.Join(p.AllowedIPs, ","))
+	}
+	if len(p.Reserved) > 0 {
+		reserved := make([]string, len(p.Reserved))
+		for i, n := range p.Reserved {
+			reserved[i] = strconv.Itoa(n)
+		}
+		params.Set("reserved", strings.Join(reserved, ","))
+	}
+	if p.MTU > 0 {
+		params.Set("mtu", strconv.Itoa(p.MTU))
+	}
+	return params
+}
+
+func withPrefix(addr string, prefix string) string {
+	if strings.Contains(addr, "/") {
+		return addr
+	}
+	return addr + prefix
+}
+
+// proxyTLSParams holds the TLS settings of a socks5 or http proxy as read by
+// applyProxyTLSParams.
+func (p *Proxy) proxyTLSParams() url.Values {
+	params := url.Values{}
+	if p.SNI != "" {
+		params.Set("sni", p.SNI)
+	}
+	if p.SkipCertVerify {
+		params.Set("allowInsecure", "1")
+	}
+	return params
+}
+
+func (p *Proxy) authUserinfo() string {
+	if p.Username == "" && p.Password == "" {
+		return ""
+	}
+	return escapeComponent(p.Username) + ":" + escapeComponent(p.Password)
+}
+
+// escapeComponent percent-encodes a link component. Spaces become %20 rather
+// than '+' because the parsers unescape remarks with url.QueryUnescape.
+func escapeComponent(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
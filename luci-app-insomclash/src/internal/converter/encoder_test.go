@@ -0,0 +1,313 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToLinkRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		proxy Proxy
+	}{
+		{
+			name: "vmess ws tls",
+			proxy: Proxy{
+				Name:      "vmess-ws",
+				Type:      ProxyTypeVMess,
+				Server:    "example.com",
+				Port:      443,
+				UUID:      "b831381d-6324-4d53-ad4f-8cda48b30811",
+				Cipher:    "auto",
+				UDP:       true,
+				TLS:       true,
+				SNI:       "cdn.example.com",
+				Network:   "ws",
+				WSPath:    "/ws",
+				WSHeaders: map[string]string{"Host": "cdn.example.com"},
+			},
+		},
+		{
+			name: "vmess grpc",
+			proxy: Proxy{
+				Name:            "vmess-grpc",
+				Type:            ProxyTypeVMess,
+				Server:          "1.2.3.4",
+				Port:            8443,
+				UUID:            "b831381d-6324-4d53-ad4f-8cda48b30811",
+				Cipher:          "auto",
+				UDP:             true,
+				TLS:             true,
+				Network:         "grpc",
+				GRPCServiceName: "svc",
+			},
+		},
+		{
+			name: "vless reality",
+			proxy: Proxy{
+				Name:              "vless-reality",
+				Type:              ProxyTypeVLess,
+				Server:            "example.com",
+				Port:              443,
+				UUID:              "b831381d-6324-4d53-ad4f-8cda48b30811",
+				UDP:               true,
+				TLS:               true,
+				SNI:               "www.microsoft.com",
+				Network:           "tcp",
+				Flow:              "xtls-rprx-vision",
+				ClientFingerprint: "chrome",
+				RealityOpts: &RealityOptions{
+					PublicKey: "jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0",
+					ShortID:   "0123abcd",
+				},
+			},
+		},
+		{
+			name: "vless ws plain",
+			proxy: Proxy{
+				Name:    "vless-ws",
+				Type:    ProxyTypeVLess,
+				Server:  "example.com",
+				Port:    80,
+				UUID:    "b831381d-6324-4d53-ad4f-8cda48b30811",
+				UDP:     true,
+				Network: "ws",
+				WSPath:  "/path",
+			},
+		},
+		{
+			name: "trojan",
+			proxy: Proxy{
+				Name:           "trojan",
+				Type:           ProxyTypeTrojan,
+				Server:         "example.com",
+				Port:           443,
+				Password:       "p@ss:word",
+				UDP:            true,
+				TLS:            true,
+				SNI:            "example.com",
+				Network:        "tcp",
+				SkipCertVerify: true,
+			},
+		},
+		{
+			name: "shadowsocks",
+			proxy: Proxy{
+				Name:     "ss",
+				Type:     ProxyTypeSS,
+				Server:   "example.com",
+				Port:     8388,
+				Cipher:   "aes-256-gcm",
+				Password: "secret",
+				UDP:      true,
+			},
+		},
+		{
+			name: "shadowsocks ipv6",
+			proxy: Proxy{
+				Name:     "ss-v6",
+				Type:     ProxyTypeSS,
+				Server:   "2001:db8::1",
+				Port:     8388,
+				Cipher:   "chacha20-ietf-poly1305",
+				Password: "secret",
+				UDP:      true,
+			},
+		},
+		{
+			name: "shadowsocks obfs plugin",
+			proxy: Proxy{
+				Name:     "ss-obfs",
+				Type:     ProxyTypeSS,
+				Server:   "example.com",
+				Port:     8388,
+				Cipher:   "aes-128-gcm",
+				Password: "secret",
+				UDP:      true,
+				Plugin:   "obfs",
+				PluginOpts: map[string]interface{}{
+					"mode": "http",
+					"host": "bing.com",
+				},
+			},
+		},
+		{
+			name: "shadowsocksr",
+			proxy: Proxy{
+				Name:          "ssr",
+				Type:          ProxyTypeSSR,
+				Server:        "example.com",
+				Port:          8989,
+				Cipher:        "aes-256-cfb",
+				Password:      "secret",
+				UDP:           true,
+				Protocol:      "auth_aes128_md5",
+				ProtocolParam: "1:abc",
+				Obfs:          "tls1.2_ticket_auth",
+				ObfsParam:     "example.org",
+			},
+		},
+		{
+			name: "hysteria2",
+			proxy: Proxy{
+				Name:         "hy2",
+				Type:         ProxyTypeHysteria2,
+				Server:       "example.com",
+				Port:         443,
+				Password:     "secret",
+				UDP:          true,
+				SNI:          "example.com",
+				Obfs:         "salamander",
+				ObfsPassword: "obfs",
+			},
+		},
+		{
+			name: "tuic",
+			proxy: Proxy{
+				Name:              "tuic",
+				Type:              ProxyTypeTUIC,
+				Server:            "example.com",
+				Port:              443,
+				UUID:              "b831381d-6324-4d53-ad4f-8cda48b30811",
+				Password:          "secret",
+				UDP:               true,
+				SNI:               "example.com",
+				ALPN:              []string{"h3"},
+				CongestionControl: "bbr",
+				UDPRelayMode:      "native",
+			},
+		},
+		{
+			name: "wireguard",
+			proxy: Proxy{
+				Name:       "wg",
+				Type:       ProxyTypeWireGuard,
+				Server:     "162.159.192.1",
+				Port:       2408,
+				PrivateKey: "eCtXsJZ27+4PbhDkHnB923tkUn2Gj59wZw5wFA75MnU=",
+				PublicKey:  "Cr8hWlKvtDt7nrvf+f0brNQQzabAqrjfBvas9pmowjo=",
+				IP:         "172.16.0.2",
+				UDP:        true,
+				MTU:        1280,
+			},
+		},
+		{
+			name: "socks5",
+			proxy: Proxy{
+				Name:     "socks",
+				Type:     ProxyTypeSocks5,
+				Server:   "example.com",
+				Port:     1080,
+				Username: "user",
+				Password: "pass",
+				UDP:      true,
+			},
+		},
+		{
+			name: "socks5 tls",
+			proxy: Proxy{
+				Name:           "socks-tls",
+				Type:           ProxyTypeSocks5,
+				Server:         "example.com",
+				Port:           1080,
+				Username:       "user",
+				Password:       "pass",
+				UDP:            true,
+				TLS:            true,
+				SNI:            "tls.example.com",
+				SkipCertVerify: true,
+			},
+		},
+		{
+			name: "http",
+			proxy: Proxy{
+				Name:     "http",
+				Type:     ProxyTypeHTTP,
+				Server:   "example.com",
+				Port:     8080,
+				Username: "user",
+				Password: "pass",
+			},
+		},
+		{
+			name: "https",
+			proxy: Proxy{
+				Name:     "https",
+				Type:     ProxyTypeHTTP,
+				Server:   "example.com",
+				Port:     443,
+				Username: "user",
+				Password: "pass",
+				TLS:      true,
+			},
+		},
+		{
+			name: "https sni insecure",
+			proxy: Proxy{
+				Name:           "https-sni",
+				Type:           ProxyTypeHTTP,
+				Server:         "1.2.3.4",
+				Port:           443,
+				Username:       "user",
+				Password:       "pass",
+				TLS:            true,
+				SNI:            "example.com",
+				SkipCertVerify: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link, err := tt.proxy.ToLink()
+			if err != nil {
+				t.Fatalf("ToLink() error = %v", err)
+			}
+
+			got, err := ParseLink(link)
+			if err != nil {
+				t.Fatalf("ParseLink(%q) error = %v", link, err)
+			}
+			if !reflect.DeepEqual(*got, tt.proxy) {
+				t.Errorf("ParseLink(%q)\n got  %+v\n want %+v", link, *got, tt.proxy)
+			}
+		})
+	}
+}
+
+func TestToLinkWireGuardPeers(t *testing.T) {
+	peer := WireGuardPeer{
+		Server:     "162.159.192.1",
+		Port:       2408,
+		PublicKey:  "Cr8hWlKvtDt7nrvf+f0brNQQzabAqrjfBvas9pmowjo=",
+		AllowedIPs: []string{"0.0.0.0/0"},
+	}
+	proxy := Proxy{
+		Name:       "wg",
+		Type:       ProxyTypeWireGuard,
+		PrivateKey: "eCtXsJZ27+4PbhDkHnB923tkUn2Gj59wZw5wFA75MnU=",
+		IP:         "172.16.0.2",
+		UDP:        true,
+		Peers:      []WireGuardPeer{peer},
+	}
+
+	link, err := proxy.ToLink()
+	if err != nil {
+		t.Fatalf("ToLink() with one peer error = %v", err)
+	}
+	got, err := ParseLink(link)
+	if err != nil {
+		t.Fatalf("ParseLink(%q) error = %v", link, err)
+	}
+	if got.Server != peer.Server || got.Port != peer.Port || got.PublicKey != peer.PublicKey {
+		t.Errorf("ParseLink(%q) peer = %s:%d %s, want %s:%d %s",
+			link, got.Server, got.Port, got.PublicKey, peer.Server, peer.Port, peer.PublicKey)
+	}
+
+	second := peer
+	second.Server = "162.159.193.1"
+	proxy.Peers = append(proxy.Peers, second)
+	if link, err := proxy.ToLink(); err == nil {
+		t.Errorf("ToLink() with two peers = %q, want error", link)
+	}
+}
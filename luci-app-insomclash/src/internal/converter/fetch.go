@@ -0,0 +1,214 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultUserAgent makes most airports serve a full mihomo profile
+	// instead of a plain link list.
+	DefaultUserAgent = "clash.meta"
+
+	DefaultFetchTimeout = 30 * time.Second
+	DefaultMaxSize      = 10 << 20
+	DefaultMaxRedirects = 5
+)
+
+// ErrBlockedAddress is returned when BlockPrivate stops a request to a
+// loopback, private or link-local address.
+var ErrBlockedAddress = errors.New("subscription address is not allowed")
+
+// FetchOptions controls how a subscription is downloaded. Zero values use
+// the defaults above.
+type FetchOptions struct {
+	UserAgent string
+	Timeout   time.Duration
+	// MaxSize limits the response body in bytes.
+	MaxSize int64
+	// MaxRedirects limits followed redirects; negative disables them.
+	MaxRedirects int
+	// BlockPrivate refuses loopback, private and link-local targets,
+	// including redirect targets.
+	BlockPrivate bool
+	// Proxy is an http or socks5 proxy URL to fetch through, such as the
+	// mixed-port of the running mihomo.
+	Proxy string
+	// ETag and LastModified from an earlier fetch make the request
+	// conditional.
+	ETag         string
+	LastModified string
+}
+
+// FetchSubscription downloads and parses a subscription. When the body cannot
+// be parsed the returned subscription still carries the parse report and
+// quota info next to the error. A conditional request answered with 304
+// returns a subscription with NotModified set and no proxies.
+func FetchSubscription(rawURL string, opts FetchOptions) (*Subscription, error) {
+	client, err := newFetchClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription url: %v", err)
+	}
+
+	// Through a proxy the dialer only sees the proxy address, so check the
+	// target up front; redirects are checked in CheckRedirect.
+	if opts.BlockPrivate && opts.Proxy != "" {
+		if err := checkHost(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+
+	userAgent := opts.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	if opts.ETag != "" {
+		req.Header.Set("If-None-Match", opts.ETag)
+	}
+	if opts.LastModified != "" {
+		req.Header.Set("If-Modified-Since", opts.LastModified)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlockedAddress) {
+			return nil, ErrBlockedAddress
+		}
+		return nil, fmt.Errorf("failed to fetch subscription: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return &Subscription{
+			Info:         ParseSubscriptionInfo(resp.Header),
+			ETag:         opts.ETag,
+			LastModified: opts.LastModified,
+			NotModified:  true,
+		}, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("subscription returned status %d", resp.StatusCode)
+	}
+
+	maxSize := opts.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subscription body: %v", err)
+	}
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("subscription is larger than %d bytes", maxSize)
+	}
+
+	sub, err := ParseSubscriptionContent(string(body))
+	sub.Info = ParseSubscriptionInfo(resp.Header)
+	sub.ETag = resp.Header.Get("ETag")
+	sub.LastModified = resp.Header.Get("Last-Modified")
+	if err != nil {
+		return sub, err
+	}
+
+	return sub, nil
+}
+
+func newFetchClient(opts FetchOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %v", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	} else if opts.BlockPrivate {
+		// Checking the resolved address at dial time also covers redirects
+		// and DNS answers that change between lookups.
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip != nil && isBlockedIP(ip) {
+					return ErrBlockedAddress
+				}
+				return nil
+			},
+		}
+		transport.Proxy = nil
+		transport.DialContext = dialer.DialContext
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultFetchTimeout
+	}
+
+	maxRedirects := opts.MaxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if maxRedirects < 0 {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if opts.BlockPrivate && opts.Proxy != "" {
+				return checkHost(req.Context(), req.URL.Hostname())
+			}
+			return nil
+		},
+	}, nil
+}
+
+// checkHost resolves host and fails if any of its addresses is blocked.
+func checkHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if isBlockedIP(ip) {
+			return ErrBlockedAddress
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %v", host, err)
+	}
+	for _, addr := range addrs {
+		if isBlockedIP(addr.IP) {
+			return ErrBlockedAddress
+		}
+	}
+	return nil
+}
+
+func isBlockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
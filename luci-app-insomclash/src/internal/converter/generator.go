@@ -0,0 +1,362 @@
+package converter
+
+import (
+	_ "embed"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+//go:embed templates/default.yaml
+var defaultTemplate []byte
+
+const (
+	defaultMainGroup = "Proxy"
+	defaultTestURL   = "http://www.gstatic.com/generate_204"
+	defaultInterval  = 300
+)
+
+// DefaultRegionGroups are used when GenerateOptions.RegionGroups is nil.
+var DefaultRegionGroups = []RegionGroup{
+	{Name: "Hong Kong", Filter: "(?i)香港|hong ?kong|🇭🇰|\\bHK\\b"},
+	{Name: "Taiwan", Filter: "(?i)台湾|臺灣|taiwan|🇹🇼|\\bTW\\b"},
+	{Name: "Japan", Filter: "(?i)日本|japan|tokyo|osaka|🇯🇵|\\bJP\\b"},
+	{Name: "Singapore", Filter: "(?i)新加坡|狮城|singapore|🇸🇬|\\bSG\\b"},
+	{Name: "United States", Filter: "(?i)美国|united states|america|🇺🇸|\\bUSA?\\b"},
+	{Name: "Indonesia", Filter: "(?i)印尼|indonesia|jakarta|🇮🇩|\\bID\\b"},
+}
+
+// GenerateOptions describes the proxy part of a generated mihomo config. The
+// rest of the config (ports, DNS, sniffer, rules, ...) comes from the
+// template.
+type GenerateOptions struct {
+	// Providers are proxy_providers files referenced as file providers.
+	Providers []string `json:"providers,omitempty"`
+	// Proxies are embedded into the config's proxies: list.
+	Proxies []*Proxy `json:"proxies,omitempty"`
+
+	MainGroup    string        `json:"main_group,omitempty" example:"Proxy"`
+	RegionGroups []RegionGroup `json:"region_groups,omitempty"`
+	URLTest      bool          `json:"url_test"`
+	Fallback     bool          `json:"fallback"`
+	LoadBalance  bool          `json:"load_balance"`
+	TestURL      string        `json:"test_url,omitempty"`
+	Interval     int           `json:"interval,omitempty"`
+}
+
+// RegionGroup is a url-test group of the proxies whose name matches Filter.
+type RegionGroup struct {
+	Name   string `json:"name" example:"Hong Kong"`
+	Filter string `json:"filter" example:"(?i)香港|HK"`
+}
+
+// DefaultTemplate returns the built-in template based on the bundled config.
+func DefaultTemplate() []byte {
+	return defaultTemplate
+}
+
+// GenerateConfig builds a complete mihomo config from template, replacing its
+// proxies, proxy-providers and the proxy-groups it generates. Template rules
+// and other template groups are kept; when the template has no rules a MATCH
+// rule to the main group is added. The main group defaults to the target of
+// the template's MATCH rule so its rules keep working. A kept group or rule
+// that refers to something missing from the generated config is an error,
+// since mihomo would refuse to load it.
+func GenerateConfig(template []byte, opts GenerateOptions) ([]byte, error) {
+	if len(template) == 0 {
+		template = defaultTemplate
+	}
+
+	var config yaml.MapSlice
+	if err := yaml.Unmarshal(template, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse template: %v", err)
+	}
+
+	if len(opts.Providers) == 0 && len(opts.Proxies) == 0 {
+		return nil, fmt.Errorf("no proxies or providers to generate a config from")
+	}
+
+	rules := mapStrings(config, "rules")
+
+	mainGroup := opts.MainGroup
+	if mainGroup == "" {
+		mainGroup = matchTarget(rules)
+	}
+	if mainGroup == "" {
+		mainGroup = defaultMainGroup
+	}
+
+	if opts.TestURL == "" {
+		opts.TestURL = defaultTestURL
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	if opts.RegionGroups == nil {
+		opts.RegionGroups = DefaultRegionGroups
+	}
+
+	UniqueNames(opts.Proxies)
+
+	providers, providerNames := generateProviders(opts)
+
+	groups, err := generateGroups(opts, mainGroup, providerNames)
+	if err != nil {
+		return nil, err
+	}
+	groups = append(groups, templateGroups(config, groups)...)
+
+	if err := checkPolicies(groups, rules, opts.Proxies, providerNames); err != nil {
+		return nil, err
+	}
+
+	var proxies []yaml.MapSlice
+	for _, proxy := range opts.Proxies {
+		proxies = append(proxies, proxy.ToMihomo())
+	}
+
+	var result yaml.MapSlice
+	for _, item := range config {
+		switch item.Key {
+		case "proxies", "proxy-providers", "proxy-groups", "rules":
+			continue
+		}
+		result = append(result, item)
+	}
+
+	if len(proxies) > 0 {
+		result = append(result, yaml.MapItem{Key: "proxies", Value: proxies})
+	}
+	if len(providers) > 0 {
+		result = append(result, yaml.MapItem{Key: "proxy-providers", Value: providers})
+	}
+	result = append(result, yaml.MapItem{Key: "proxy-groups", Value: groups})
+
+	if len(rules) == 0 {
+		rules = []string{"MATCH," + mainGroup}
+	}
+	result = append(result, yaml.MapItem{Key: "rules", Value: rules})
+
+	data, err := yaml.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %v", err)
+	}
+	return data, nil
+}
+
+func generateProviders(opts GenerateOptions) (yaml.MapSlice, []string) {
+	var providers yaml.MapSlice
+	var names []string
+	used := make(map[string]bool)
+
+	for _, file := range opts.Providers {
+		name := strings.TrimSuffix(file, filepath.Ext(file))
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s-%d", strings.TrimSuffix(file, filepath.Ext(file)), n)
+		}
+		used[name] = true
+		names = append(names, name)
+
+		providers = append(providers, yaml.MapItem{Key: name, Value: yaml.MapSlice{
+			{Key: "type", Value: "file"},
+			{Key: "path", Value: "./proxy_providers/" + file},
+			{Key: "health-check", Value: yaml.MapSlice{
+				{Key: "enable", Value: true},
+				{Key: "url", Value: opts.TestURL},
+				{Key: "interval", Value: opts.Interval},
+			}},
+		}})
+	}
+
+	return providers, names
+}
+
+func generateGroups(opts GenerateOptions, mainGroup string, providers []string) ([]yaml.MapSlice, error) {
+	var proxyNames []string
+	for _, proxy := range opts.Proxies {
+		proxyNames = append(proxyNames, proxy.Name)
+	}
+
+	member := func(name string, typ string, proxies []string, filter string) yaml.MapSlice {
+		m := mihomoMap{}
+		m.setAlways("name", name)
+		m.setAlways("type", typ)
+		if typ != "select" {
+			m.setAlways("url", opts.TestURL)
+			m.setAlways("interval", opts.Interval)
+		}
+		if typ == "load-balance" {
+			m.setAlways("strategy", "consistent-hashing")
+		}
+		m.set("proxies", proxies)
+		if len(providers) > 0 {
+			m.setAlways("use", providers)
+			m.set("filter", filter)
+		}
+		return m.slice
+	}
+
+	var groups []yaml.MapSlice
+	var mainMembers []string
+
+	auto := []struct {
+		enabled bool
+		name    string
+		typ     string
+	}{
+		{opts.URLTest, "Auto", "url-test"},
+		{opts.Fallback, "Fallback", "fallback"},
+		{opts.LoadBalance, "Load Balance", "load-balance"},
+	}
+	for _, g := range auto {
+		if !g.enabled {
+			continue
+		}
+		groups = append(groups, member(g.name, g.typ, proxyNames, ""))
+		mainMembers = append(mainMembers, g.name)
+	}
+
+	for _, region := range opts.RegionGroups {
+		if region.Name == "" || region.Filter == "" {
+			return nil, fmt.Errorf("region groups need a name and a filter")
+		}
+
+		re, err := regexp.Compile(region.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter for region group %s: %v", region.Name, err)
+		}
+
+		var matched []string
+		for _, name := range proxyNames {
+			if re.MatchString(name) {
+				matched = append(matched, name)
+			}
+		}
+
+		// Without providers there is nothing the filter could match later.
+		if len(matched) == 0 && len(providers) == 0 {
+			continue
+		}
+
+		groups = append(groups, member(region.Name, "url-test", matched, region.Filter))
+		mainMembers = append(mainMembers, region.Name)
+	}
+
+	mainMembers = append(mainMembers, proxyNames...)
+	mainMembers = append(mainMembers, "DIRECT")
+
+	main := member(mainGroup, "select", mainMembers, "")
+	return append([]yaml.MapSlice{main}, groups...), nil
+}
+
+// templateGroups returns the template's proxy groups that were not
+// generated, in template order.
+func templateGroups(config yaml.MapSlice, generated []yaml.MapSlice) []yaml.MapSlice {
+	names := make(map[string]bool)
+	for _, group := range generated {
+		names[mapString(group, "name")] = true
+	}
+
+	list, _ := mapValue(config, "proxy-groups").([]interface{})
+
+	var groups []yaml.MapSlice
+	for _, item := range list {
+		group, ok := item.(yaml.MapSlice)
+		if !ok || names[mapString(group, "name")] {
+			continue
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// builtinPolicies are the policies mihomo provides without a definition.
+var builtinPolicies = []string{"DIRECT", "REJECT", "REJECT-DROP", "PASS", "COMPATIBLE", "GLOBAL"}
+
+// checkPolicies makes sure every group member, group provider and rule
+// target exists in the generated config.
+func checkPolicies(groups []yaml.MapSlice, rules []string, proxies []*Proxy, providers []string) error {
+	policies := make(map[string]bool)
+	for _, name := range builtinPolicies {
+		policies[name] = true
+	}
+	for _, proxy := range proxies {
+		policies[proxy.Name] = true
+	}
+	for _, group := range groups {
+		policies[mapString(group, "name")] = true
+	}
+
+	used := make(map[string]bool)
+	for _, name := range providers {
+		used[name] = true
+	}
+
+	for _, group := range groups {
+		name := mapString(group, "name")
+		for _, member := range mapStrings(group, "proxies") {
+			if !policies[member] {
+				return fmt.Errorf("proxy group %s uses %s, which is not in the generated config", name, member)
+			}
+		}
+		for _, provider := range mapStrings(group, "use") {
+			if !used[provider] {
+				return fmt.Errorf("proxy group %s uses provider %s, which is not in the generated config", name, provider)
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		if target := ruleTarget(rule); target != "" && !policies[target] {
+			return fmt.Errorf("rule %q targets %s, which is not in the generated config", rule, target)
+		}
+	}
+	return nil
+}
+
+// ruleTarget returns the policy of a rule. Commas inside the parenthesised
+// conditions of logical rules are skipped; SUB-RULE targets a sub-rule set
+// rather than a policy, so it has none.
+func ruleTarget(rule string) string {
+	var fields []string
+	depth, start := 0, 0
+	for i, r := range rule {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				fields = append(fields, strings.TrimSpace(rule[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	fields = append(fields, strings.TrimSpace(rule[start:]))
+
+	switch {
+	case len(fields) >= 2 && fields[0] == "MATCH":
+		return fields[1]
+	case len(fields) >= 3 && fields[0] != "SUB-RULE":
+		return fields[2]
+	}
+	return ""
+}
+
+// matchTarget returns the policy of the final MATCH rule, if any.
+func matchTarget(rules []string) string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		parts := strings.Split(rules[i], ",")
+		if len(parts) >= 2 && strings.TrimSpace(parts[0]) == "MATCH" {
+			target := strings.TrimSpace(parts[1])
+			if target != "DIRECT" && target != "REJECT" {
+				return target
+			}
+		}
+	}
+	return ""
+}
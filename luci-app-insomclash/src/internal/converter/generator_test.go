@@ -0,0 +1,135 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const groupsTemplate = `mixed-port: 7890
+rule-providers:
+  streaming:
+    type: http
+    behavior: domain
+    url: https://example.com/streaming.yaml
+    path: ./rules/streaming.yaml
+proxy-groups:
+  - name: Proxy
+    type: select
+    proxies: [old-node]
+  - name: Streaming
+    type: select
+    proxies: [Proxy, DIRECT]
+rules:
+  - RULE-SET,streaming,Streaming
+  - AND,((NETWORK,UDP),(DST-PORT,443)),REJECT
+  - MATCH,Proxy
+`
+
+func TestGenerateConfigKeepsTemplateGroups(t *testing.T) {
+	opts := GenerateOptions{
+		Proxies: []*Proxy{{
+			Name:     "node",
+			Type:     ProxyTypeTrojan,
+			Server:   "example.com",
+			Port:     443,
+			Password: "secret",
+		}},
+		RegionGroups: []RegionGroup{},
+	}
+
+	data, err := GenerateConfig([]byte(groupsTemplate), opts)
+	if err != nil {
+		t.Fatalf("GenerateConfig() error = %v", err)
+	}
+
+	var config yaml.MapSlice
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatalf("generated config does not parse: %v", err)
+	}
+
+	groups := make(map[string][]string)
+	for _, item := range mapValue(config, "proxy-groups").([]interface{}) {
+		group := item.(yaml.MapSlice)
+		groups[mapString(group, "name")] = mapStrings(group, "proxies")
+	}
+
+	if got, want := strings.Join(groups["Proxy"], ","), "node,DIRECT"; got != want {
+		t.Errorf("Proxy group members = %s, want %s", got, want)
+	}
+	if got, want := strings.Join(groups["Streaming"], ","), "Proxy,DIRECT"; got != want {
+		t.Errorf("Streaming group members = %s, want %s", got, want)
+	}
+	if rules := mapStrings(config, "rules"); len(rules) != 3 || rules[0] != "RULE-SET,streaming,Streaming" {
+		t.Errorf("rules = %q, want the template rules", rules)
+	}
+	if mapValue(config, "rule-providers") == nil {
+		t.Error("rule-providers were dropped")
+	}
+}
+
+func TestGenerateConfigMissingPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{
+			name: "rule to missing group",
+			template: `rules:
+  - RULE-SET,streaming,Streaming
+  - MATCH,Proxy
+`,
+		},
+		{
+			name: "group with template proxy",
+			template: `proxy-groups:
+  - name: Streaming
+    type: select
+    proxies: [old-node, DIRECT]
+rules:
+  - MATCH,Proxy
+`,
+		},
+		{
+			name: "group with template provider",
+			template: `proxy-groups:
+  - name: Streaming
+    type: select
+    use: [old-provider]
+rules:
+  - MATCH,Proxy
+`,
+		},
+	}
+
+	opts := GenerateOptions{Providers: []string{"sub.yaml"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if data, err := GenerateConfig([]byte(tt.template), opts); err == nil {
+				t.Errorf("GenerateConfig() = %s, want error", data)
+			}
+		})
+	}
+}
+
+func TestRuleTarget(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{rule: "DOMAIN-SUFFIX,google.com,Proxy", want: "Proxy"},
+		{rule: "IP-CIDR,10.0.0.0/8,DIRECT,no-resolve", want: "DIRECT"},
+		{rule: "AND,((DOMAIN,example.com),(NETWORK,UDP)),Streaming", want: "Streaming"},
+		{rule: "MATCH, Proxy", want: "Proxy"},
+		{rule: "SUB-RULE,(NETWORK,TCP),tcp-rules", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			if got := ruleTarget(tt.rule); got != tt.want {
+				t.Errorf("ruleTarget(%q) = %q, want %q", tt.rule, got, tt.want)
+			}
+		})
+	}
+}
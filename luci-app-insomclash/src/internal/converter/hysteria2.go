@@ -0,0 +1,123 @@
+package converter
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func ParseHysteria2(link string) (*Proxy, error) {
+	var rest string
+	if strings.HasPrefix(link, "hysteria2://") {
+		rest = strings.TrimPrefix(link, "hysteria2://")
+	} else if strings.HasPrefix(link, "hy2://") {
+		rest = strings.TrimPrefix(link, "hy2://")
+	} else {
+		return nil, fmt.Errorf("invalid hysteria2 link")
+	}
+
+	var remark string
+	if idx := strings.Index(rest, "#"); idx != -1 {
+		remark, _ = url.QueryUnescape(rest[idx+1:])
+		rest = rest[:idx]
+	}
+
+	var query string
+	if idx := strings.Index(rest, "?"); idx != -1 {
+		query = rest[idx+1:]
+		rest = rest[:idx]
+	}
+
+	rest = strings.TrimSuffix(rest, "/")
+
+	var auth string
+	if idx := strings.LastIndex(rest, "@"); idx != -1 {
+		auth, _ = url.PathUnescape(rest[:idx])
+		rest = rest[idx+1:]
+	}
+
+	server, portSpec, err := splitHostPortSpec(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	var ports string
+	firstPort := portSpec
+	if strings.ContainsAny(portSpec, ",-") {
+		ports = portSpec
+		fields := strings.FieldsFunc(portSpec, func(r rune) bool {
+			return r == ',' || r == '-'
+		})
+		if len(fields) > 0 {
+			firstPort = fields[0]
+		}
+	}
+
+	port, err := strconv.Atoi(firstPort)
+	if err != nil {
+		return nil, parseError(ErrorBadPort, "invalid port: %v", err)
+	}
+
+	proxy := &Proxy{
+		Name:     remark,
+		Type:     ProxyTypeHysteria2,
+		Server:   server,
+		Port:     port,
+		Password: auth,
+		Ports:    ports,
+		UDP:      true,
+	}
+
+	if query != "" {
+		params, _ := url.ParseQuery(query)
+
+		if obfs := params.Get("obfs"); obfs != "" && obfs != "none" {
+			proxy.Obfs = obfs
+			proxy.ObfsPassword = params.Get("obfs-password")
+		}
+
+		if sni := params.Get("sni"); sni != "" {
+			proxy.SNI = sni
+		}
+
+		if insecure := params.Get("insecure"); insecure == "1" || insecure == "true" {
+			proxy.SkipCertVerify = true
+		}
+
+		if pin := params.Get("pinSHA256"); pin != "" {
+			proxy.Fingerprint = strings.ToLower(strings.ReplaceAll(pin, ":", ""))
+		}
+
+		if mport := params.Get("mport"); mport != "" {
+			proxy.Ports = mport
+		}
+
+		if alpn := params.Get("alpn"); alpn != "" {
+			proxy.ALPN = strings.Split(alpn, ",")
+		}
+
+		if up := firstParam(params, "up", "upmbps"); up != "" {
+			proxy.Up = up
+		}
+
+		if down := firstParam(params, "down", "downmbps"); down != "" {
+			proxy.Down = down
+		}
+	}
+
+	if proxy.Name == "" {
+		proxy.Name = fmt.Sprintf("%s:%d", server, port)
+	}
+
+	return proxy, nil
+}
+
+func firstParam(params url.Values, keys ...string) string {
+	for _, key := range keys {
+		if value := params.Get(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
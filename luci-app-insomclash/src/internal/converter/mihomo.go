@@ -0,0 +1,680 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type mihomoProvider struct {
+	Proxies []yaml.MapSlice `yaml:"proxies"`
+}
+
+func ToMihomoYAML(proxies []*Proxy) ([]byte, error) {
+	provider := mihomoProvider{Proxies: make([]yaml.MapSlice, 0, len(proxies))}
+	for _, proxy := range proxies {
+		provider.Proxies = append(provider.Proxies, proxy.ToMihomo())
+	}
+
+	data, err := yaml.Marshal(provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal mihomo proxies: %v", err)
+	}
+
+	return data, nil
+}
+
+// ToMihomo renders the proxy with the key names and nesting mihomo expects
+// in a proxies: list, keeping a stable key order for readable provider files.
+func (p *Proxy) ToMihomo() yaml.MapSlice {
+	m := mihomoMap{}
+
+	m.set("name", p.Name)
+	m.set("type", string(p.Type))
+	m.set("server", p.Server)
+	m.set("port", p.Port)
+
+	switch p.Type {
+	case ProxyTypeVMess:
+		m.set("uuid", p.UUID)
+		m.setAlways("alterId", p.AlterId)
+		m.set("cipher", p.Cipher)
+		m.set("udp", p.UDP)
+		m.setTLS(p, "servername")
+		m.setTransport(p)
+	case ProxyTypeVLess:
+		m.set("uuid", p.UUID)
+		m.set("flow", p.Flow)
+		m.set("udp", p.UDP)
+		m.setTLS(p, "servername")
+		m.setTransport(p)
+	case ProxyTypeTrojan:
+		m.set("password", p.Password)
+		m.set("udp", p.UDP)
+		m.setTLS(p, "sni")
+		m.setTransport(p)
+	case ProxyTypeSS:
+		m.set("cipher", p.Cipher)
+		m.set("password", p.Password)
+		m.set("udp", p.UDP)
+		m.set("plugin", p.Plugin)
+		if len(p.PluginOpts) > 0 {
+			m.set("plugin-opts", p.PluginOpts)
+		}
+	case ProxyTypeSSR:
+		m.set("cipher", p.Cipher)
+		m.set("password", p.Password)
+		m.set("obfs", p.Obfs)
+		m.set("protocol", p.Protocol)
+		m.set("obfs-param", p.ObfsParam)
+		m.set("protocol-param", p.ProtocolParam)
+		m.set("udp", p.UDP)
+	case ProxyTypeHysteria2:
+		m.set("ports", p.Ports)
+		m.set("password", p.Password)
+		m.set("up", p.Up)
+		m.set("down", p.Down)
+		m.set("obfs", p.Obfs)
+		m.set("obfs-password", p.ObfsPassword)
+		m.set("sni", p.SNI)
+		m.set("skip-cert-verify", p.SkipCertVerify)
+		m.set("fingerprint", p.Fingerprint)
+		m.set("alpn", p.ALPN)
+	case ProxyTypeTUIC:
+		m.set("uuid", p.UUID)
+		m.set("password", p.Password)
+		m.set("sni", p.SNI)
+		m.set("alpn", p.ALPN)
+		m.set("skip-cert-verify", p.SkipCertVerify)
+		m.set("disable-sni", p.DisableSNI)
+		m.set("reduce-rtt", p.ReduceRTT)
+		m.set("congestion-controller", p.CongestionControl)
+		m.set("udp-relay-mode", p.UDPRelayMode)
+		m.set("udp", p.UDP)
+	case ProxyTypeWireGuard:
+		m.set("ip", p.IP)
+		m.set("ipv6", p.IPv6)
+		m.set("private-key", p.PrivateKey)
+		m.set("public-key", p.PublicKey)
+		m.set("pre-shared-key", p.PreSharedKey)
+		m.set("allowed-ips", p.AllowedIPs)
+		m.set("reserved", p.Reserved)
+		m.set("mtu", p.MTU)
+		m.set("udp", p.UDP)
+		if len(p.Peers) > 0 {
+			peers := make([]yaml.MapSlice, 0, len(p.Peers))
+			for _, peer := range p.Peers {
+				pm := mihomoMap{}
+				pm.set("server", peer.Server)
+				pm.set("port", peer.Port)
+				pm.set("public-key", peer.PublicKey)
+				pm.set("pre-shared-key", peer.PreSharedKey)
+				pm.set("reserved", peer.Reserved)
+				pm.set("allowed-ips", peer.AllowedIPs)
+				peers = append(peers, pm.slice)
+			}
+			m.set("peers", peers)
+		}
+	case ProxyTypeSocks5:
+		m.set("username", p.Username)
+		m.set("password", p.Password)
+		m.set("tls", p.TLS)
+		m.set("skip-cert-verify", p.SkipCertVerify)
+		m.set("udp", p.UDP)
+	case ProxyTypeHTTP:
+		m.set("username", p.Username)
+		m.set("password", p.Password)
+		m.set("tls", p.TLS)
+		m.set("sni", p.SNI)
+		m.set("skip-cert-verify", p.SkipCertVerify)
+	}
+
+	if len(p.Extra) > 0 {
+		return mergeMapSlice(m.slice, mapToMapSlice(p.Extra))
+	}
+
+	return m.slice
+}
+
+type mihomoMap struct {
+	slice yaml.MapSlice
+}
+
+func (m *mihomoMap) setAlways(key string, value interface{}) {
+	m.slice = append(m.slice, yaml.MapItem{Key: key, Value: value})
+}
+
+// set skips zero values so optional fields never reach the provider file.
+func (m *mihomoMap) set(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return
+		}
+	case int:
+		if v == 0 {
+			return
+		}
+	case bool:
+		if !v {
+			return
+		}
+	case []string:
+		if len(v) == 0 {
+			return
+		}
+	case []int:
+		if len(v) == 0 {
+			return
+		}
+	case map[string]string:
+		if len(v) == 0 {
+			return
+		}
+	case yaml.MapSlice:
+		if len(v) == 0 {
+			return
+		}
+	case nil:
+		return
+	}
+	m.setAlways(key, value)
+}
+
+func (m *mihomoMap) setTLS(p *Proxy, sniKey string) {
+	m.set("tls", p.TLS)
+	m.set(sniKey, p.SNI)
+	m.set("alpn", p.ALPN)
+	m.set("skip-cert-verify", p.SkipCertVerify)
+	m.set("client-fingerprint", p.ClientFingerprint)
+
+	if p.RealityOpts != nil {
+		opts := mihomoMap{}
+		opts.set("public-key", p.RealityOpts.PublicKey)
+		opts.set("short-id", p.RealityOpts.ShortID)
+		m.set("reality-opts", opts.slice)
+	}
+}
+
+func (m *mihomoMap) setTransport(p *Proxy) {
+	switch p.Network {
+	case "ws", "websocket":
+		m.set("network", "ws")
+		opts := mihomoMap{}
+		opts.set("path", p.WSPath)
+		opts.set("headers", p.WSHeaders)
+		m.set("ws-opts", opts.slice)
+	case "httpupgrade":
+		m.set("network", "ws")
+		opts := mihomoMap{}
+		opts.set("path", p.HTTPUpgradePath)
+		opts.set("headers", p.WSHeaders)
+		opts.set("v2ray-http-upgrade", true)
+		m.set("ws-opts", opts.slice)
+	case "grpc":
+		m.set("network", "grpc")
+		opts := mihomoMap{}
+		opts.set("grpc-service-name", p.GRPCServiceName)
+		m.set("grpc-opts", opts.slice)
+	case "xhttp", "splithttp":
+		m.set("network", "xhttp")
+		path := p.XHTTPPath
+		if path == "" {
+			path = p.SplitHTTPPath
+		}
+		opts := mihomoMap{}
+		opts.set("path", path)
+		if host := p.WSHeaders["Host"]; host != "" {
+			opts.set("host", host)
+		}
+		opts.set("mode", p.XHTTPMode)
+		for _, keys := range xhttpExtraKeys {
+			if value, ok := p.XHTTPExtra[keys[0]]; ok {
+				opts.set(keys[1], sliceValue(value))
+			}
+		}
+		m.set("xhttp-opts", opts.slice)
+	case "h2":
+		m.set("network", "h2")
+		if p.H2Opts != nil {
+			opts := mihomoMap{}
+			opts.set("host", p.H2Opts.Host)
+			opts.set("path", p.H2Opts.Path)
+			m.set("h2-opts", opts.slice)
+		}
+	case "http":
+		m.set("network", "http")
+		if p.HTTPOpts != nil {
+			opts := mihomoMap{}
+			opts.set("method", p.HTTPOpts.Method)
+			opts.set("path", p.HTTPOpts.Path)
+			if len(p.HTTPOpts.Headers) > 0 {
+				headers := make(map[string]interface{}, len(p.HTTPOpts.Headers))
+				for key, values := range p.HTTPOpts.Headers {
+					headers[key] = values
+				}
+				opts.set("headers", mapToMapSlice(headers))
+			}
+			m.set("http-opts", opts.slice)
+		}
+	case "", "tcp":
+	default:
+		m.set("network", p.Network)
+	}
+}
+
+// IsMihomoYAML reports whether content looks like a Clash/mihomo config or
+// provider file carrying a top-level proxies: list.
+func IsMihomoYAML(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimRight(line, " \r"), "proxies:") {
+			return true
+		}
+	}
+	return false
+}
+
+func ParseMihomoProxies(data []byte) ([]*Proxy, error) {
+	var provider mihomoProvider
+	if err := yaml.Unmarshal(data, &provider); err != nil {
+		return nil, fmt.Errorf("failed to parse mihomo proxies: %v", err)
+	}
+
+	var proxies []*Proxy
+	var errors []string
+	for _, item := range provider.Proxies {
+		proxy, err := FromMihomo(item)
+		if err != nil {
+			errors = append(errors, err.Error())
+			continue
+		}
+		proxies = append(proxies, proxy)
+	}
+
+	if len(proxies) == 0 && len(errors) > 0 {
+		return nil, fmt.Errorf("failed to parse any proxies: %v", errors)
+	}
+
+	return proxies, nil
+}
+
+// FromMihomo is the inverse of ToMihomo: it reads one entry of a mihomo
+// proxies: list back into a Proxy.
+func FromMihomo(m yaml.MapSlice) (*Proxy, error) {
+	proxy := &Proxy{
+		Name:   mapString(m, "name"),
+		Type:   ProxyType(mapString(m, "type")),
+		Server: mapString(m, "server"),
+		Port:   mapInt(m, "port"),
+		UDP:    mapBool(m, "udp"),
+	}
+
+	if proxy.Server == "" {
+		return nil, fmt.Errorf("proxy %q has no server", proxy.Name)
+	}
+
+	proxy.TLS = mapBool(m, "tls")
+	proxy.SNI = mapString(m, "servername")
+	if proxy.SNI == "" {
+		proxy.SNI = mapString(m, "sni")
+	}
+	proxy.ALPN = mapStrings(m, "alpn")
+	proxy.SkipCertVerify = mapBool(m, "skip-cert-verify")
+	proxy.ClientFingerprint = mapString(m, "client-fingerprint")
+	proxy.Password = mapString(m, "password")
+	proxy.UUID = mapString(m, "uuid")
+
+	if reality := mapMap(m, "reality-opts"); reality != nil {
+		proxy.RealityOpts = &RealityOptions{
+			PublicKey: mapString(reality, "public-key"),
+			ShortID:   mapString(reality, "short-id"),
+		}
+	}
+
+	switch proxy.Type {
+	case ProxyTypeVMess:
+		proxy.AlterId = mapInt(m, "alterId")
+		proxy.Cipher = mapString(m, "cipher")
+		readMihomoTransport(proxy, m)
+	case ProxyTypeVLess:
+		proxy.Flow = mapString(m, "flow")
+		readMihomoTransport(proxy, m)
+	case ProxyTypeTrojan:
+		readMihomoTransport(proxy, m)
+	case ProxyTypeSS:
+		proxy.Cipher = mapString(m, "cipher")
+		proxy.Plugin = mapString(m, "plugin")
+		if opts := mapMap(m, "plugin-opts"); opts != nil {
+			proxy.PluginOpts = make(map[string]interface{}, len(opts))
+			for _, item := range opts {
+				proxy.PluginOpts[fmt.Sprintf("%v", item.Key)] = item.Value
+			}
+		}
+	case ProxyTypeSSR:
+		proxy.Cipher = mapString(m, "cipher")
+		proxy.Obfs = mapString(m, "obfs")
+		proxy.Protocol = mapString(m, "protocol")
+		proxy.ObfsParam = mapString(m, "obfs-param")
+		proxy.ProtocolParam = mapString(m, "protocol-param")
+	case ProxyTypeHysteria2:
+		proxy.UDP = true
+		proxy.Ports = mapString(m, "ports")
+		proxy.Obfs = mapString(m, "obfs")
+		proxy.ObfsPassword = mapString(m, "obfs-password")
+		proxy.Fingerprint = mapString(m, "fingerprint")
+		proxy.Up = mapString(m, "up")
+		proxy.Down = mapString(m, "down")
+	case ProxyTypeTUIC:
+		proxy.CongestionControl = mapString(m, "congestion-controller")
+		proxy.UDPRelayMode = mapString(m, "udp-relay-mode")
+		proxy.DisableSNI = mapBool(m, "disable-sni")
+		proxy.ReduceRTT = mapBool(m, "reduce-rtt")
+	case ProxyTypeWireGuard:
+		proxy.IP = mapString(m, "ip")
+		proxy.IPv6 = mapString(m, "ipv6")
+		proxy.PrivateKey = mapString(m, "private-key")
+		proxy.PublicKey = mapString(m, "public-key")
+		proxy.PreSharedKey = mapString(m, "pre-shared-key")
+		proxy.AllowedIPs = mapStrings(m, "allowed-ips")
+		proxy.Reserved = mapReserved(m, "reserved")
+		proxy.MTU = mapInt(m, "mtu")
+		if peers, ok := mapValue(m, "peers").([]interface{}); ok {
+			for _, item := range peers {
+				pm, ok := item.(yaml.MapSlice)
+				if !ok {
+					continue
+				}
+				proxy.Peers = append(proxy.Peers, WireGuardPeer{
+					Server:       mapString(pm, "server"),
+					Port:         mapInt(pm, "port"),
+					PublicKey:    mapString(pm, "public-key"),
+					PreSharedKey: mapString(pm, "pre-shared-key"),
+					Reserved:     mapReserved(pm, "reserved"),
+					AllowedIPs:   mapStrings(pm, "allowed-ips"),
+				})
+			}
+		}
+	case ProxyTypeSocks5, ProxyTypeHTTP:
+		proxy.Username = mapString(m, "username")
+	default:
+		if !passthroughTypes[proxy.Type] {
+			return nil, fmt.Errorf("proxy %q has unsupported type %q", proxy.Name, proxy.Type)
+		}
+	}
+
+	if proxy.Name == "" {
+		proxy.Name = fmt.Sprintf("%s:%d", proxy.Server, proxy.Port)
+	}
+
+	// Whatever ToMihomo would not write back is kept verbatim, so fields this
+	// package does not model survive a parse/export round trip.
+	if extra := diffMapSlice(m, proxy.ToMihomo()); len(extra) > 0 {
+		proxy.Extra = mapSliceToMap(extra)
+		for _, key := range consumedAliases(proxy) {
+			delete(proxy.Extra, key)
+		}
+		if len(proxy.Extra) == 0 {
+			proxy.Extra = nil
+		}
+	}
+
+	return proxy, nil
+}
+
+// consumedAliases lists input keys that were read into a field but are
+// written back under their canonical mihomo name.
+func consumedAliases(proxy *Proxy) []string {
+	aliases := []string{"ws-path", "ws-headers"}
+	switch proxy.Type {
+	case ProxyTypeVMess, ProxyTypeVLess:
+		aliases = append(aliases, "sni")
+	case ProxyTypeTrojan:
+		aliases = append(aliases, "servername")
+	}
+	return aliases
+}
+
+// passthroughTypes are mihomo proxy types without link support; they are
+// carried through Extra unchanged.
+var passthroughTypes = map[ProxyType]bool{
+	"hysteria": true,
+	"snell":    true,
+	"ssh":      true,
+	"anytls":   true,
+	"mieru":    true,
+}
+
+func readMihomoTransport(proxy *Proxy, m yaml.MapSlice) {
+	network := mapString(m, "network")
+
+	switch network {
+	case "ws":
+		opts := mapMap(m, "ws-opts")
+		path := mapString(opts, "path")
+		headers := mapMap(opts, "headers")
+		if opts == nil {
+			// legacy Clash keys
+			path = mapString(m, "ws-path")
+			headers = mapMap(m, "ws-headers")
+		}
+		if headers != nil {
+			proxy.WSHeaders = make(map[string]string, len(headers))
+			for _, item := range headers {
+				proxy.WSHeaders[fmt.Sprintf("%v", item.Key)] = getStringValue(item.Value)
+			}
+		}
+		if mapBool(opts, "v2ray-http-upgrade") {
+			proxy.Network = "httpupgrade"
+			proxy.HTTPUpgradePath = path
+		} else {
+			proxy.Network = "ws"
+			proxy.WSPath = path
+		}
+	case "grpc":
+		proxy.Network = network
+		proxy.GRPCServiceName = mapString(mapMap(m, "grpc-opts"), "grpc-service-name")
+	case "xhttp":
+		proxy.Network = network
+		opts := mapMap(m, "xhttp-opts")
+		proxy.XHTTPPath = mapString(opts, "path")
+		if host := mapString(opts, "host"); host != "" {
+			proxy.WSHeaders = map[string]string{"Host": host}
+		}
+		proxy.XHTTPMode = mapString(opts, "mode")
+		for _, keys := range xhttpExtraKeys {
+			if value := mapValue(opts, keys[1]); value != nil {
+				if proxy.XHTTPExtra == nil {
+					proxy.XHTTPExtra = make(map[string]interface{})
+				}
+				proxy.XHTTPExtra[keys[0]] = plainValue(value)
+			}
+		}
+	case "h2":
+		proxy.Network = network
+		opts := mapMap(m, "h2-opts")
+		proxy.H2Opts = &H2Options{
+			Host: mapStrings(opts, "host"),
+			Path: mapString(opts, "path"),
+		}
+	case "http":
+		proxy.Network = network
+		opts := mapMap(m, "http-opts")
+		proxy.HTTPOpts = &HTTPOptions{
+			Method: mapString(opts, "method"),
+			Path:   mapStrings(opts, "path"),
+		}
+		if headers := mapMap(opts, "headers"); headers != nil {
+			proxy.HTTPOpts.Headers = make(map[string][]string, len(headers))
+			for _, item := range headers {
+				key := fmt.Sprintf("%v", item.Key)
+				proxy.HTTPOpts.Headers[key] = mapStrings(headers, key)
+			}
+		}
+	default:
+		proxy.Network = network
+	}
+}
+
+func mapValue(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if k, ok := item.Key.(string); ok && k == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+func mapString(m yaml.MapSlice, key string) string {
+	return getStringValue(mapValue(m, key))
+}
+
+func mapInt(m yaml.MapSlice, key string) int {
+	n, _ := strconv.Atoi(mapString(m, key))
+	return n
+}
+
+func mapBool(m yaml.MapSlice, key string) bool {
+	switch v := mapValue(m, key).(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true" || v == "1"
+	case int:
+		return v != 0
+	}
+	return false
+}
+
+func mapMap(m yaml.MapSlice, key string) yaml.MapSlice {
+	if v, ok := mapValue(m, key).(yaml.MapSlice); ok {
+		return v
+	}
+	return nil
+}
+
+func mapStrings(m yaml.MapSlice, key string) []string {
+	switch v := mapValue(m, key).(type) {
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			result = append(result, getStringValue(item))
+		}
+		return result
+	case string:
+		return splitList(v)
+	}
+	return nil
+}
+
+func mapReserved(m yaml.MapSlice, key string) []int {
+	switch v := mapValue(m, key).(type) {
+	case []interface{}:
+		result := make([]int, 0, len(v))
+		for _, item := range v {
+			n, _ := strconv.Atoi(getStringValue(item))
+			result = append(result, n)
+		}
+		return result
+	case string:
+		reserved, _ := parseReserved(v)
+		return reserved
+	}
+	return nil
+}
+
+func diffMapSlice(orig, emitted yaml.MapSlice) yaml.MapSlice {
+	var extra yaml.MapSlice
+	for _, item := range orig {
+		key, _ := item.Key.(string)
+		value := mapValue(emitted, key)
+		if value == nil {
+			extra = append(extra, item)
+			continue
+		}
+
+		origMap, ok1 := item.Value.(yaml.MapSlice)
+		emittedMap, ok2 := value.(yaml.MapSlice)
+		if ok1 && ok2 {
+			if nested := diffMapSlice(origMap, emittedMap); len(nested) > 0 {
+				extra = append(extra, yaml.MapItem{Key: key, Value: nested})
+			}
+		}
+	}
+	return extra
+}
+
+func mergeMapSlice(base, extra yaml.MapSlice) yaml.MapSlice {
+	for _, item := range extra {
+		key, _ := item.Key.(string)
+		found := false
+		for i := range base {
+			if k, _ := base[i].Key.(string); k != key {
+				continue
+			}
+			found = true
+			baseMap, ok1 := base[i].Value.(yaml.MapSlice)
+			extraMap, ok2 := item.Value.(yaml.MapSlice)
+			if ok1 && ok2 {
+				base[i].Value = mergeMapSlice(baseMap, extraMap)
+			}
+			break
+		}
+		if !found {
+			base = append(base, item)
+		}
+	}
+	return base
+}
+
+func mapSliceToMap(m yaml.MapSlice) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for _, item := range m {
+		result[fmt.Sprintf("%v", item.Key)] = plainValue(item.Value)
+	}
+	return result
+}
+
+func plainValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case yaml.MapSlice:
+		return mapSliceToMap(val)
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i, item := range val {
+			result[i] = plainValue(item)
+		}
+		return result
+	}
+	return v
+}
+
+func mapToMapSlice(m map[string]interface{}) yaml.MapSlice {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make(yaml.MapSlice, 0, len(m))
+	for _, key := range keys {
+		result = append(result, yaml.MapItem{Key: key, Value: sliceValue(m[key])})
+	}
+	return result
+}
+
+func sliceValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return mapToMapSlice(val)
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i, item := range val {
+			result[i] = sliceValue(item)
+		}
+		return result
+	}
+	return v
+}
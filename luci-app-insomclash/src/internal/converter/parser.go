@@ -1,13 +1,40 @@
 package converter
 
 import (
-	"fmt"
+	"encoding/base64"
 	"strings"
 )
 
+var linkPrefixes = []string{
+	"vmess://", "vless://", "trojan://", "ss://", "ssr://",
+	"hysteria2://", "hy2://", "tuic://", "wireguard://", "wg://",
+	"socks://", "socks5://", "socks5h://", "socks5+tls://",
+}
+
+func IsProxyLink(link string) bool {
+	for _, prefix := range linkPrefixes {
+		if strings.HasPrefix(link, prefix) {
+			return true
+		}
+	}
+	return IsHTTPProxyLink(link)
+}
+
+// ParseLink parses a single share link. Errors are *ParseError values that
+// tell why the link was rejected.
 func ParseLink(link string) (*Proxy, error) {
-	link = strings.TrimSpace(link)
+	proxy, err := parseLink(strings.TrimSpace(link))
+	if err != nil {
+		return nil, err
+	}
 
+	if err := checkProxy(proxy); err != nil {
+		return nil, err
+	}
+	return proxy, nil
+}
+
+func parseLink(link string) (*Proxy, error) {
 	if strings.HasPrefix(link, "vmess://") {
 		return ParseVMess(link)
 	} else if strings.HasPrefix(link, "vless://") {
@@ -16,34 +43,71 @@ func ParseLink(link string) (*Proxy, error) {
 		return ParseTrojan(link)
 	} else if strings.HasPrefix(link, "ss://") {
 		return ParseSS(link)
+	} else if strings.HasPrefix(link, "hysteria2://") || strings.HasPrefix(link, "hy2://") {
+		return ParseHysteria2(link)
+	} else if strings.HasPrefix(link, "tuic://") {
+		return ParseTUIC(link)
+	} else if strings.HasPrefix(link, "wireguard://") || strings.HasPrefix(link, "wg://") {
+		return ParseWireGuard(link)
+	} else if strings.HasPrefix(link, "ssr://") {
+		return ParseSSR(link)
+	} else if strings.HasPrefix(link, "socks") {
+		return ParseSocks(link)
+	} else if IsHTTPProxyLink(link) {
+		return ParseHTTP(link)
 	}
 
-	return nil, fmt.Errorf("unsupported link format: %s", link[:min(20, len(link))])
+	// Only the scheme is reported: the rest of the link may hold credentials.
+	if scheme, _, ok := strings.Cut(link, "://"); ok && scheme != "" && !strings.ContainsAny(scheme, "@:/?#") {
+		return nil, parseError(ErrorUnsupportedProtocol, "unsupported link scheme %q", scheme)
+	}
+	return nil, parseError(ErrorUnsupportedProtocol, "unsupported link format")
 }
 
+// ParseLinks parses share links, skipping the ones that fail. It only returns
+// an error when none of them could be parsed; use ParseLinksReport to see why
+// individual links failed.
 func ParseLinks(links []string) ([]*Proxy, error) {
+	proxies, report := ParseLinksReport(links)
+	if len(proxies) == 0 && report.Failed > 0 {
+		return nil, report.err("links")
+	}
+
+	return proxies, nil
+}
+
+// ParseLinksReport parses share links and reports the outcome of every
+// non-empty link, with its position in links, next to the parsed proxies.
+func ParseLinksReport(links []string) ([]*Proxy, *ParseReport) {
 	var proxies []*Proxy
-	var errors []string
+	report := newParseReport()
 
-	for _, link := range links {
+	for i, link := range links {
+		link = strings.TrimSpace(link)
 		if link == "" {
 			continue
 		}
 
 		proxy, err := ParseLink(link)
+		report.add(i, link, LinkType(link), proxy, err)
 		if err != nil {
-			errors = append(errors, err.Error())
 			continue
 		}
 
 		proxies = append(proxies, proxy)
 	}
 
-	if len(proxies) == 0 && len(errors) > 0 {
-		return nil, fmt.Errorf("failed to parse any links: %v", errors)
-	}
+	return proxies, report
+}
 
-	return proxies, nil
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+
+	decoded, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		decoded, err = base64.RawStdEncoding.DecodeString(s)
+	}
+	return decoded, err
 }
 
 func min(a, b int) int {
@@ -0,0 +1,31 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLinksReportHidesCredentials(t *testing.T) {
+	links := []string{
+		"anytls://secretpassword@example.com:443?sni=example.com#node",
+		"secretpassword@example.com:443",
+		"trojan://secretpassword@example.com",
+	}
+
+	_, report := ParseLinksReport(links)
+	if len(report.Results) != len(links) {
+		t.Fatalf("ParseLinksReport() results = %d, want %d", len(report.Results), len(links))
+	}
+	for _, result := range report.Results {
+		if result.Error == "" {
+			t.Errorf("result %d error is empty, want a failure", result.Index)
+		}
+		if strings.Contains(result.Error, "secretpassword") {
+			t.Errorf("result %d error = %q, want no credentials", result.Index, result.Error)
+		}
+	}
+
+	if got := report.Results[0].Error; !strings.Contains(got, `"anytls"`) {
+		t.Errorf("unsupported scheme error = %q, want it to name the scheme", got)
+	}
+}
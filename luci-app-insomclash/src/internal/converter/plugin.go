@@ -0,0 +1,329 @@
+package converter
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Shadowsocks links, SIP008 and sing-box describe plugins the SIP003 way: a
+// plugin binary name and a "key=value;flag" option string. Mihomo has its own
+// plugin names and typed plugin-opts, so the helpers below translate between
+// the two for the plugins mihomo implements. Other plugins are passed
+// through with their options as strings.
+
+// parseSIP002Plugin parses the plugin parameter of an ss link, which holds the
+// plugin name and its options separated by ";".
+func parseSIP002Plugin(plugin string) (string, map[string]interface{}, error) {
+	name, opts := plugin, ""
+	if idx := strings.Index(plugin, ";"); idx != -1 {
+		name, opts = plugin[:idx], plugin[idx+1:]
+	}
+	return mihomoPlugin(name, opts)
+}
+
+// mihomoPlugin translates a SIP003 plugin name and option string to the
+// mihomo plugin name and plugin-opts.
+func mihomoPlugin(name, opts string) (string, map[string]interface{}, error) {
+	name = strings.TrimSpace(name)
+	values := splitPluginOpts(opts)
+
+	switch name {
+	case "obfs-local", "simple-obfs", "obfs":
+		pluginOpts, err := obfsPluginOpts(values)
+		return "obfs", pluginOpts, err
+	case "v2ray-plugin":
+		pluginOpts, err := v2rayPluginOpts(values)
+		return name, pluginOpts, err
+	case "shadow-tls":
+		pluginOpts, err := shadowTLSPluginOpts(values)
+		return name, pluginOpts, err
+	case "restls":
+		return name, restlsPluginOpts(values), nil
+	}
+
+	if len(values) == 0 {
+		return name, nil, nil
+	}
+	pluginOpts := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if value == "" {
+			value = "true"
+		}
+		pluginOpts[key] = value
+	}
+	return name, pluginOpts, nil
+}
+
+func obfsPluginOpts(values map[string]string) (map[string]interface{}, error) {
+	mode := firstValue(values, "obfs", "mode")
+	if mode == "" {
+		mode = "http"
+	}
+	if mode != "http" && mode != "tls" {
+		return nil, parseError(ErrorInvalid, "unsupported obfs mode: %s", mode)
+	}
+
+	opts := map[string]interface{}{"mode": mode}
+	if host := firstValue(values, "obfs-host", "host"); host != "" {
+		opts["host"] = host
+	}
+	return opts, nil
+}
+
+func v2rayPluginOpts(values map[string]string) (map[string]interface{}, error) {
+	mode := values["mode"]
+	if mode == "" {
+		mode = "websocket"
+	}
+	if mode != "websocket" {
+		return nil, parseError(ErrorUnsupportedTransport, "unsupported v2ray-plugin mode: %s", mode)
+	}
+
+	opts := map[string]interface{}{"mode": mode}
+	if value, ok := values["tls"]; ok {
+		opts["tls"] = pluginFlag(value)
+	}
+	if host := values["host"]; host != "" {
+		opts["host"] = host
+	}
+	if path := values["path"]; path != "" {
+		opts["path"] = path
+	}
+	// v2ray-plugin takes a mux concurrency, mihomo only an on/off switch.
+	if value, ok := values["mux"]; ok {
+		opts["mux"] = pluginFlag(value)
+	}
+	if value, ok := values["skip-cert-verify"]; ok {
+		opts["skip-cert-verify"] = pluginFlag(value)
+	}
+	if fingerprint := values["fingerprint"]; fingerprint != "" {
+		opts["fingerprint"] = fingerprint
+	}
+	return opts, nil
+}
+
+func shadowTLSPluginOpts(values map[string]string) (map[string]interface{}, error) {
+	version := 2
+	if value := values["version"]; value != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(value, "v"))
+		if err != nil || n < 1 || n > 3 {
+			return nil, parseError(ErrorInvalid, "unsupported shadow-tls version: %s", value)
+		}
+		version = n
+	}
+
+	opts := map[string]interface{}{"version": version}
+	if host := values["host"]; host != "" {
+		opts["host"] = host
+	}
+	// Version 1 has no password.
+	if password := firstValue(values, "password", "passwd"); password != "" && version > 1 {
+		opts["password"] = password
+	}
+	if fingerprint := values["fingerprint"]; fingerprint != "" {
+		opts["fingerprint"] = fingerprint
+	}
+	if value, ok := values["skip-cert-verify"]; ok {
+		opts["skip-cert-verify"] = pluginFlag(value)
+	}
+	if alpn := values["alpn"]; alpn != "" {
+		opts["alpn"] = strings.Split(alpn, ",")
+	}
+	return opts, nil
+}
+
+func restlsPluginOpts(values map[string]string) map[string]interface{} {
+	opts := make(map[string]interface{})
+	for _, key := range []string{"host", "password", "version-hint", "restls-script"} {
+		if value := values[key]; value != "" {
+			opts[key] = value
+		}
+	}
+	return opts
+}
+
+// sip003Plugin is the reverse of mihomoPlugin: it returns the SIP003 plugin
+// name and option string for the proxy's mihomo plugin.
+func (p *Proxy) sip003Plugin() (string, string) {
+	opts := p.PluginOpts
+
+	switch p.Plugin {
+	case "obfs":
+		mode := pluginOptString(opts, "mode")
+		if mode == "" {
+			mode = "http"
+		}
+		values := [][2]string{{"obfs", mode}}
+		if host := pluginOptString(opts, "host"); host != "" {
+			values = append(values, [2]string{"obfs-host", host})
+		}
+		return "obfs-local", formatPluginValues(values)
+	case "v2ray-plugin":
+		var values [][2]string
+		if mode := pluginOptString(opts, "mode"); mode != "" && mode != "websocket" {
+			values = append(values, [2]string{"mode", mode})
+		}
+		if pluginOptBool(opts, "tls") {
+			values = append(values, [2]string{"tls", ""})
+		}
+		if host := pluginOptString(opts, "host"); host != "" {
+			values = append(values, [2]string{"host", host})
+		}
+		if path := pluginOptString(opts, "path"); path != "" {
+			values = append(values, [2]string{"path", path})
+		}
+		// Mux is on by default in v2ray-plugin but off in mihomo.
+		if !pluginOptBool(opts, "mux") {
+			values = append(values, [2]string{"mux", "0"})
+		}
+		return p.Plugin, formatPluginValues(values)
+	case "shadow-tls":
+		var values [][2]string
+		if host := pluginOptString(opts, "host"); host != "" {
+			values = append(values, [2]string{"host", host})
+		}
+		if password := pluginOptString(opts, "password"); password != "" {
+			values = append(values, [2]string{"password", password})
+		}
+		version := pluginOptString(opts, "version")
+		if version == "" {
+			version = "2"
+		}
+		values = append(values, [2]string{"version", version})
+		return p.Plugin, formatPluginValues(values)
+	case "restls":
+		var values [][2]string
+		for _, key := range []string{"host", "password", "version-hint", "restls-script"} {
+			if value := pluginOptString(opts, key); value != "" {
+				values = append(values, [2]string{key, value})
+			}
+		}
+		return p.Plugin, formatPluginValues(values)
+	}
+
+	keys := make([]string, 0, len(opts))
+	for key := range opts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([][2]string, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, [2]string{key, pluginOptString(opts, key)})
+	}
+	return p.Plugin, formatPluginValues(values)
+}
+
+// sip002Plugin returns the plugin parameter of an ss link.
+func (p *Proxy) sip002Plugin() string {
+	name, opts := p.sip003Plugin()
+	if opts == "" {
+		return name
+	}
+	return name + ";" + opts
+}
+
+// splitPluginOpts splits a SIP003 option string. Flags without a value map to
+// an empty string; "\" escapes ";", "=" and "\" in keys and values.
+func splitPluginOpts(opts string) map[string]string {
+	values := make(map[string]string)
+
+	var key, current strings.Builder
+	inValue := false
+	flush := func() {
+		name := strings.TrimSpace(key.String())
+		if !inValue {
+			name = strings.TrimSpace(current.String())
+		}
+		if name != "" {
+			if inValue {
+				values[name] = current.String()
+			} else {
+				values[name] = ""
+			}
+		}
+		key.Reset()
+		current.Reset()
+		inValue = false
+	}
+
+	for i := 0; i < len(opts); i++ {
+		c := opts[i]
+		switch {
+		case c == '\\' && i+1 < len(opts):
+			i++
+			current.WriteByte(opts[i])
+		case c == '=' && !inValue:
+			key.WriteString(current.String())
+			current.Reset()
+			inValue = true
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+
+	return values
+}
+
+// formatPluginValues joins options in order, escaping SIP003 separators.
+// An empty value is written as a bare flag.
+func formatPluginValues(values [][2]string) string {
+	escape := strings.NewReplacer(`\`, `\\`, `;`, `\;`, `=`, `\=`)
+
+	parts := make([]string, 0, len(values))
+	for _, kv := range values {
+		if kv[1] == "" {
+			parts = append(parts, escape.Replace(kv[0]))
+			continue
+		}
+		parts = append(parts, escape.Replace(kv[0])+"="+escape.Replace(kv[1]))
+	}
+	return strings.Join(parts, ";")
+}
+
+func pluginOptString(opts map[string]interface{}, key string) string {
+	switch v := opts[key].(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, getStringValue(item))
+		}
+		return strings.Join(items, ",")
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return getStringValue(v)
+	}
+}
+
+func pluginOptBool(opts map[string]interface{}, key string) bool {
+	if _, ok := opts[key]; !ok {
+		return false
+	}
+	return pluginFlag(pluginOptString(opts, key))
+}
+
+// pluginFlag reads a SIP003 flag, where a bare flag means true.
+func pluginFlag(value string) bool {
+	switch strings.ToLower(value) {
+	case "", "true", "1", "yes", "on":
+		return true
+	}
+	n, err := strconv.Atoi(value)
+	return err == nil && n > 0
+}
+
+func firstValue(values map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if value := values[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,269 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ProcessOptions configures the clean-up applied to parsed proxies. Steps run
+// in this order: info node removal, include/exclude filters, rename rules,
+// de-duplication, country tagging and finally unique naming.
+type ProcessOptions struct {
+	SkipInfoNodes bool         `json:"skip_info_nodes,omitempty"`
+	IncludeName   string       `json:"include_name,omitempty" example:"HK|SG"`
+	ExcludeName   string       `json:"exclude_name,omitempty"`
+	IncludeType   string       `json:"include_type,omitempty" example:"vless|trojan"`
+	ExcludeType   string       `json:"exclude_type,omitempty"`
+	IncludeServer string       `json:"include_server,omitempty"`
+	ExcludeServer string       `json:"exclude_server,omitempty"`
+	Rename        []RenameRule `json:"rename,omitempty"`
+	Dedupe        bool         `json:"dedupe,omitempty"`
+	CountryTag    string       `json:"country_tag,omitempty" example:"emoji"`
+}
+
+// RenameRule replaces Pattern in proxy names; Replace may use $1 style
+// capture group references.
+type RenameRule struct {
+	Pattern string `json:"pattern" example:"^\\[(\\w+)\\]\\s*(.*)$"`
+	Replace string `json:"replace" example:"$2 $1"`
+}
+
+const (
+	CountryTagEmoji = "emoji"
+	CountryTagCode  = "code"
+)
+
+// infoNodePattern matches the pseudo nodes airports use to show traffic,
+// expiry or their website in the proxy list.
+var infoNodePattern = regexp.MustCompile(`(?i)剩余|流量|到期|过期|有效期|官网|网址|套餐|重置|距离下次|expire|traffic|remaining|website`)
+
+var countryPatterns = []struct {
+	code    string
+	pattern *regexp.Regexp
+}{
+	{"HK", regexp.MustCompile(`(?i)香港|hong\s*kong|(?:^|[^a-z])hk(?:[^a-z]|$)`)},
+	{"TW", regexp.MustCompile(`(?i)台湾|臺灣|taiwan|(?:^|[^a-z])tw(?:[^a-z]|$)`)},
+	{"MO", regexp.MustCompile(`(?i)澳门|macau|macao`)},
+	{"JP", regexp.MustCompile(`(?i)日本|japan|tokyo|osaka|东京|大阪|(?:^|[^a-z])jp(?:[^a-z]|$)`)},
+	{"KR", regexp.MustCompile(`(?i)韩国|korea|seoul|首尔|(?:^|[^a-z])kr(?:[^a-z]|$)`)},
+	{"SG", regexp.MustCompile(`(?i)新加坡|狮城|singapore|(?:^|[^a-z])sg(?:[^a-z]|$)`)},
+	{"US", regexp.MustCompile(`(?i)美国|united\s*states|america|los\s*angeles|san\s*jose|洛杉矶|(?:^|[^a-z])usa?(?:[^a-z]|$)`)},
+	{"GB", regexp.MustCompile(`(?i)英国|united\s*kingdom|britain|london|伦敦|(?:^|[^a-z])(?:uk|gb)(?:[^a-z]|$)`)},
+	{"DE", regexp.MustCompile(`(?i)德国|germany|frankfurt|(?:^|[^a-z])de(?:[^a-z]|$)`)},
+	{"FR", regexp.MustCompile(`(?i)法国|france|paris|(?:^|[^a-z])fr(?:[^a-z]|$)`)},
+	{"NL", regexp.MustCompile(`(?i)荷兰|netherlands|amsterdam|(?:^|[^a-z])nl(?:[^a-z]|$)`)},
+	{"RU", regexp.MustCompile(`(?i)俄罗斯|russia|moscow|(?:^|[^a-z])ru(?:[^a-z]|$)`)},
+	{"CA", regexp.MustCompile(`(?i)加拿大|canada|(?:^|[^a-z])ca(?:[^a-z]|$)`)},
+	{"AU", regexp.MustCompile(`(?i)澳大利亚|澳洲|australia|sydney|(?:^|[^a-z])au(?:[^a-z]|$)`)},
+	{"IN", regexp.MustCompile(`(?i)印度(?:[^尼]|$)|india|mumbai`)},
+	{"ID", regexp.MustCompile(`(?i)印尼|印度尼西亚|indonesia|jakarta`)},
+	{"MY", regexp.MustCompile(`(?i)马来|malaysia|(?:^|[^a-z])my(?:[^a-z]|$)`)},
+	{"TH", regexp.MustCompile(`(?i)泰国|thailand|bangkok`)},
+	{"VN", regexp.MustCompile(`(?i)越南|vietnam`)},
+	{"PH", regexp.MustCompile(`(?i)菲律宾|philippines`)},
+	{"TR", regexp.MustCompile(`(?i)土耳其|turkey|türkiye|istanbul`)},
+	{"AE", regexp.MustCompile(`(?i)阿联酋|迪拜|dubai|emirates`)},
+	{"BR", regexp.MustCompile(`(?i)巴西|brazil`)},
+	{"AR", regexp.MustCompile(`(?i)阿根廷|argentina`)},
+	{"CN", regexp.MustCompile(`(?i)中国|china|回国|(?:^|[^a-z])cn(?:[^a-z]|$)`)},
+}
+
+type compiledProcessOptions struct {
+	*ProcessOptions
+	includeName, excludeName     *regexp.Regexp
+	includeType, excludeType     *regexp.Regexp
+	includeServer, excludeServer *regexp.Regexp
+	rename                       []*regexp.Regexp
+}
+
+// ProcessProxies filters, renames, de-duplicates and tags proxies according
+// to opts. Proxies are modified in place; the returned slice always has
+// unique names. A nil opts only enforces unique names.
+func ProcessProxies(proxies []*Proxy, opts *ProcessOptions) ([]*Proxy, error) {
+	if opts == nil {
+		opts = &ProcessOptions{}
+	}
+
+	compiled, err := compileProcessOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Proxy
+	seen := make(map[string]bool)
+
+	for _, proxy := range proxies {
+		if opts.SkipInfoNodes && infoNodePattern.MatchString(proxy.Name) {
+			continue
+		}
+
+		if !compiled.accept(proxy) {
+			continue
+		}
+
+		for i, re := range compiled.rename {
+			proxy.Name = re.ReplaceAllString(proxy.Name, opts.Rename[i].Replace)
+		}
+		proxy.Name = strings.TrimSpace(proxy.Name)
+
+		if opts.Dedupe {
+			key := proxy.dedupeKey()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+
+		switch opts.CountryTag {
+		case CountryTagEmoji:
+			if code := DetectCountry(proxy.Name); code != "" && !hasFlagPrefix(proxy.Name) {
+				proxy.Name = CountryFlag(code) + " " + proxy.Name
+			}
+		case CountryTagCode:
+			if code := DetectCountry(proxy.Name); code != "" && !strings.HasPrefix(proxy.Name, code+" ") {
+				proxy.Name = code + " " + proxy.Name
+			}
+		}
+
+		result = append(result, proxy)
+	}
+
+	UniqueNames(result)
+	return result, nil
+}
+
+func compileProcessOptions(opts *ProcessOptions) (*compiledProcessOptions, error) {
+	compiled := &compiledProcessOptions{ProcessOptions: opts}
+
+	patterns := []struct {
+		name    string
+		pattern string
+		target  **regexp.Regexp
+	}{
+		{"include_name", opts.IncludeName, &compiled.includeName},
+		{"exclude_name", opts.ExcludeName, &compiled.excludeName},
+		{"include_type", opts.IncludeType, &compiled.includeType},
+		{"exclude_type", opts.ExcludeType, &compiled.excludeType},
+		{"include_server", opts.IncludeServer, &compiled.includeServer},
+		{"exclude_server", opts.ExcludeServer, &compiled.excludeServer},
+	}
+
+	for _, p := range patterns {
+		if p.pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(p.pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern: %v", p.name, err)
+		}
+		*p.target = re
+	}
+
+	for i, rule := range opts.Rename {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rename pattern %d: %v", i+1, err)
+		}
+		compiled.rename = append(compiled.rename, re)
+	}
+
+	switch opts.CountryTag {
+	case "", CountryTagEmoji, CountryTagCode:
+	default:
+		return nil, fmt.Errorf("invalid country_tag %q: must be emoji or code", opts.CountryTag)
+	}
+
+	return compiled, nil
+}
+
+func (c *compiledProcessOptions) accept(proxy *Proxy) bool {
+	checks := []struct {
+		include, exclude *regexp.Regexp
+		value            string
+	}{
+		{c.includeName, c.excludeName, proxy.Name},
+		{c.includeType, c.excludeType, string(proxy.Type)},
+		{c.includeServer, c.excludeServer, proxy.Server},
+	}
+
+	for _, check := range checks {
+		if check.include != nil && !check.include.MatchString(check.value) {
+			return false
+		}
+		if check.exclude != nil && check.exclude.MatchString(check.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// dedupeKey identifies the endpoint and credentials of a proxy, ignoring its
+// name and transport details.
+func (p *Proxy) dedupeKey() string {
+	return strings.Join([]string{
+		string(p.Type),
+		strings.ToLower(p.Server),
+		strconv.Itoa(p.Port),
+		p.UUID,
+		p.Username,
+		p.Password,
+		p.PrivateKey,
+	}, "\x00")
+}
+
+// UniqueNames renames proxies in place so every name is unique, as mihomo
+// rejects duplicates. The first occurrence keeps its name and later ones get
+// " 2", " 3", ... so names stay stable across refreshes of the same list.
+func UniqueNames(proxies []*Proxy) {
+	// Generated names must not steal a name that appears later in the list.
+	original := make(map[string]bool, len(proxies))
+	for _, proxy := range proxies {
+		if proxy.Name == "" {
+			proxy.Name = fmt.Sprintf("%s:%d", proxy.Server, proxy.Port)
+		}
+		original[proxy.Name] = true
+	}
+
+	used := make(map[string]bool, len(proxies))
+	for _, proxy := range proxies {
+		if !used[proxy.Name] {
+			used[proxy.Name] = true
+			continue
+		}
+
+		name := proxy.Name
+		for n := 2; used[name] || original[name]; n++ {
+			name = fmt.Sprintf("%s %d", proxy.Name, n)
+		}
+		used[name] = true
+		proxy.Name = name
+	}
+}
+
+// DetectCountry returns the ISO 3166 code guessed from a proxy name, or an
+// empty string.
+func DetectCountry(name string) string {
+	for _, country := range countryPatterns {
+		if country.pattern.MatchString(name) {
+			return country.code
+		}
+	}
+	return ""
+}
+
+// CountryFlag turns a two letter country code into its flag emoji.
+func CountryFlag(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		b.WriteRune(0x1F1E6 + r - 'A')
+	}
+	return b.String()
+}
+
+func hasFlagPrefix(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
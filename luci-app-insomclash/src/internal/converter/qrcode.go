@@ -0,0 +1,114 @@
+package converter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	multiqrcode "github.com/makiuchi-d/gozxing/multi/qrcode"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+const (
+	DefaultQRCodeSize = 320
+	MaxQRCodeSize     = 2048
+
+	// maxQRImagePixels bounds decoded uploads so a small compressed image
+	// cannot expand into a huge bitmap on the router.
+	maxQRImagePixels = 4096 * 4096
+)
+
+// EncodeQRCode renders content as a size x size PNG QR code. A size of 0
+// uses DefaultQRCodeSize.
+func EncodeQRCode(content string, size int) ([]byte, error) {
+	if content == "" {
+		return nil, errors.New("qr code content is empty")
+	}
+	if size == 0 {
+		size = DefaultQRCodeSize
+	}
+	if size < 64 || size > MaxQRCodeSize {
+		return nil, fmt.Errorf("qr code size must be between 64 and %d", MaxQRCodeSize)
+	}
+
+	writer := qrcode.NewQRCodeWriter()
+
+	// Medium error correction scans well from a screen; long links fall
+	// back to low to still fit in a single code.
+	var matrix *gozxing.BitMatrix
+	var err error
+	for _, level := range []string{"M", "L"} {
+		hints := map[gozxing.EncodeHintType]interface{}{
+			gozxing.EncodeHintType_ERROR_CORRECTION: level,
+			gozxing.EncodeHintType_CHARACTER_SET:    "UTF-8",
+		}
+		matrix, err = writer.Encode(content, gozxing.BarcodeFormat_QR_CODE, size, size, hints)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode qr code: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, matrix); err != nil {
+		return nil, fmt.Errorf("failed to encode png: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeQRCode reads every QR code in a PNG, JPEG or GIF image and returns
+// their texts in the order found, without duplicates.
+func DecodeQRCode(data []byte) ([]string, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image: %v", err)
+	}
+	if config.Width*config.Height > maxQRImagePixels {
+		return nil, fmt.Errorf("image is too large: %dx%d", config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %v", err)
+	}
+
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	}
+
+	var results []*gozxing.Result
+	if multi, err := multiqrcode.NewQRCodeMultiReader().DecodeMultiple(bitmap, hints); err == nil {
+		results = multi
+	} else if single, err := qrcode.NewQRCodeReader().Decode(bitmap, hints); err == nil {
+		results = []*gozxing.Result{single}
+	}
+
+	var texts []string
+	seen := make(map[string]bool)
+	for _, result := range results {
+		text := strings.TrimSpace(result.GetText())
+		if text == "" || seen[text] {
+			continue
+		}
+		seen[text] = true
+		texts = append(texts, text)
+	}
+
+	if len(texts) == 0 {
+		return nil, errors.New("no qr code found in image")
+	}
+	return texts, nil
+}
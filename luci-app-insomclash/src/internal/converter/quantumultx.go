@@ -0,0 +1,149 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+var quantumultXTypes = map[string]ProxyType{
+	"shadowsocks": ProxyTypeSS,
+	"vmess":       ProxyTypeVMess,
+	"vless":       ProxyTypeVLess,
+	"trojan":      ProxyTypeTrojan,
+	"http":        ProxyTypeHTTP,
+	"socks5":      ProxyTypeSocks5,
+}
+
+// IsQuantumultXLine reports whether line is a Quantumult X server definition
+// such as "vmess=example.com:443, method=none, password=..., tag=name".
+func IsQuantumultXLine(line string) bool {
+	idx := strings.Index(line, "=")
+	if idx == -1 {
+		return false
+	}
+
+	if _, ok := quantumultXTypes[strings.ToLower(strings.TrimSpace(line[:idx]))]; !ok {
+		return false
+	}
+
+	fields := splitFields(line[idx+1:])
+	return len(fields) > 0 && strings.Contains(fields[0], ":") && !strings.Contains(fields[0], "//")
+}
+
+// quantumultXLineType returns the proxy type a Quantumult X line declares, or
+// an empty string.
+func quantumultXLineType(line string) string {
+	idx := strings.Index(line, "=")
+	if idx == -1 {
+		return ""
+	}
+	return string(quantumultXTypes[strings.ToLower(strings.TrimSpace(line[:idx]))])
+}
+
+func ParseQuantumultXLine(line string) (*Proxy, error) {
+	idx := strings.Index(line, "=")
+	if idx == -1 {
+		return nil, parseError(ErrorMalformed, "invalid quantumult x server line")
+	}
+
+	typ, ok := quantumultXTypes[strings.ToLower(strings.TrimSpace(line[:idx]))]
+	if !ok {
+		return nil, parseError(ErrorUnsupportedProtocol, "unsupported quantumult x server type: %s", strings.TrimSpace(line[:idx]))
+	}
+
+	fields := splitFields(line[idx+1:])
+	if len(fields) == 0 {
+		return nil, parseError(ErrorMalformed, "invalid quantumult x server line")
+	}
+
+	server, port, err := splitEndpoint(fields[0])
+	if err != nil {
+		return nil, err
+	}
+
+	opts := make(map[string]string)
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) == 2 {
+			opts[strings.ToLower(strings.TrimSpace(kv[0]))] = unquote(kv[1])
+		}
+	}
+
+	proxy := &Proxy{
+		Name:     opts["tag"],
+		Type:     typ,
+		Server:   server,
+		Port:     port,
+		Password: opts["password"],
+		UDP:      opts["udp-relay"] == "true",
+		SNI:      opts["tls-host"],
+	}
+
+	proxy.SkipCertVerify = opts["tls-verification"] == "false"
+	proxy.TLS = opts["over-tls"] == "true"
+
+	obfs := opts["obfs"]
+	switch typ {
+	case ProxyTypeSS:
+		proxy.Cipher = opts["method"]
+		switch obfs {
+		case "http", "tls":
+			proxy.Plugin = "obfs"
+			proxy.PluginOpts = map[string]interface{}{"mode": obfs}
+			if host := opts["obfs-host"]; host != "" {
+				proxy.PluginOpts["host"] = host
+			}
+		case "ws", "wss":
+			proxy.Plugin = "v2ray-plugin"
+			proxy.PluginOpts = map[string]interface{}{"mode": "websocket"}
+			if host := opts["obfs-host"]; host != "" {
+				proxy.PluginOpts["host"] = host
+			}
+			if path := opts["obfs-uri"]; path != "" {
+				proxy.PluginOpts["path"] = path
+			}
+			if obfs == "wss" {
+				proxy.PluginOpts["tls"] = true
+			}
+		}
+		return withDefaultName(proxy), nil
+	case ProxyTypeVMess:
+		proxy.UUID = proxy.Password
+		proxy.Password = ""
+		proxy.Cipher = opts["method"]
+		if proxy.Cipher == "" || proxy.Cipher == "none" {
+			proxy.Cipher = "auto"
+		}
+	case ProxyTypeVLess:
+		proxy.UUID = proxy.Password
+		proxy.Password = ""
+		proxy.Flow = opts["vless-flow"]
+	case ProxyTypeHTTP, ProxyTypeSocks5:
+		proxy.Username = opts["username"]
+	}
+
+	switch obfs {
+	case "over-tls":
+		proxy.TLS = true
+	case "ws", "wss":
+		proxy.Network = "ws"
+		proxy.TLS = proxy.TLS || obfs == "wss"
+		proxy.WSPath = opts["obfs-uri"]
+		if host := opts["obfs-host"]; host != "" {
+			proxy.WSHeaders = map[string]string{"Host": host}
+		}
+	}
+
+	if proxy.SNI == "" && proxy.TLS {
+		proxy.SNI = opts["obfs-host"]
+	}
+
+	return withDefaultName(proxy), nil
+}
+
+func withDefaultName(proxy *Proxy) *Proxy {
+	if proxy.Name == "" {
+		proxy.Name = fmt.Sprintf("%s:%d", proxy.Server, proxy.Port)
+	}
+	return proxy
+}
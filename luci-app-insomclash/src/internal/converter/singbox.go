@@ -0,0 +1,412 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type outboundsConfig struct {
+	Outbounds []map[string]interface{} `json:"outbounds"`
+}
+
+// IsOutboundsJSON reports whether content is a sing-box or Xray config with
+// an outbounds array.
+func IsOutboundsJSON(content string) bool {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "{") {
+		return false
+	}
+
+	var config outboundsConfig
+	if err := json.Unmarshal([]byte(content), &config); err != nil {
+		return false
+	}
+	return len(config.Outbounds) > 0
+}
+
+// ParseOutbounds reads sing-box ("type") and Xray ("protocol") outbounds.
+// Non-proxy outbounds such as direct, block or selector groups are skipped.
+func ParseOutbounds(data []byte) ([]*Proxy, error) {
+	var config outboundsConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse outbounds: %v", err)
+	}
+
+	var proxies []*Proxy
+	var errors []string
+	for _, outbound := range config.Outbounds {
+		var proxy *Proxy
+		var err error
+		if _, ok := outbound["protocol"]; ok {
+			proxy, err = FromXray(outbound)
+		} else {
+			proxy, err = FromSingBox(outbound)
+		}
+		if err != nil {
+			errors = append(errors, err.Error())
+			continue
+		}
+		if proxy != nil {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	if len(proxies) == 0 && len(errors) > 0 {
+		return nil, fmt.Errorf("failed to parse any outbounds: %v", errors)
+	}
+
+	return proxies, nil
+}
+
+func ToSingBoxJSON(proxies []*Proxy) ([]byte, []error) {
+	config := outboundsConfig{Outbounds: []map[string]interface{}{}}
+	var errors []error
+
+	for _, proxy := range proxies {
+		outbound, err := proxy.ToSingBox()
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		config.Outbounds = append(config.Outbounds, outbound)
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, append(errors, fmt.Errorf("failed to marshal sing-box outbounds: %v", err))
+	}
+	return data, errors
+}
+
+var singBoxSkipTypes = map[string]bool{
+	"direct": true, "block": true, "dns": true, "selector": true, "urltest": true,
+}
+
+func FromSingBox(m map[string]interface{}) (*Proxy, error) {
+	outboundType := jsonString(m, "type")
+	if singBoxSkipTypes[outboundType] {
+		return nil, nil
+	}
+
+	proxy := &Proxy{
+		Name:   jsonString(m, "tag"),
+		Server: jsonString(m, "server"),
+		Port:   jsonInt(m, "server_port"),
+		UDP:    true,
+	}
+
+	switch outboundType {
+	case "vmess":
+		proxy.Type = ProxyTypeVMess
+		proxy.UUID = jsonString(m, "uuid")
+		proxy.AlterId = jsonInt(m, "alter_id")
+		proxy.Cipher = jsonString(m, "security")
+		if proxy.Cipher == "" {
+			proxy.Cipher = "auto"
+		}
+	case "vless":
+		proxy.Type = ProxyTypeVLess
+		proxy.UUID = jsonString(m, "uuid")
+		proxy.Flow = jsonString(m, "flow")
+	case "trojan":
+		proxy.Type = ProxyTypeTrojan
+		proxy.Password = jsonString(m, "password")
+	case "shadowsocks":
+		proxy.Type = ProxyTypeSS
+		proxy.Cipher = jsonString(m, "method")
+		proxy.Password = jsonString(m, "password")
+		if plugin := jsonString(m, "plugin"); plugin != "" {
+			var err error
+			proxy.Plugin, proxy.PluginOpts, err = mihomoPlugin(plugin, jsonString(m, "plugin_opts"))
+			if err != nil {
+				return nil, fmt.Errorf("outbound %q: %v", proxy.Name, err)
+			}
+		}
+	case "hysteria2":
+		proxy.Type = ProxyTypeHysteria2
+		proxy.Password = jsonString(m, "password")
+		if obfs := jsonMap(m, "obfs"); obfs != nil {
+			proxy.Obfs = jsonString(obfs, "type")
+			proxy.ObfsPassword = jsonString(obfs, "password")
+		}
+		if up := jsonInt(m, "up_mbps"); up > 0 {
+			proxy.Up = strconv.Itoa(up)
+		}
+		if down := jsonInt(m, "down_mbps"); down > 0 {
+			proxy.Down = strconv.Itoa(down)
+		}
+		if ports := jsonStrings(m, "server_ports"); len(ports) > 0 {
+			proxy.Ports = strings.ReplaceAll(strings.Join(ports, ","), ":", "-")
+		}
+	case "tuic":
+		proxy.Type = ProxyTypeTUIC
+		proxy.UUID = jsonString(m, "uuid")
+		proxy.Password = jsonString(m, "password")
+		proxy.CongestionControl = jsonString(m, "congestion_control")
+		proxy.UDPRelayMode = jsonString(m, "udp_relay_mode")
+		proxy.ReduceRTT = jsonBool(m, "zero_rtt_handshake")
+	case "socks":
+		proxy.Type = ProxyTypeSocks5
+		proxy.Username = jsonString(m, "username")
+		proxy.Password = jsonString(m, "password")
+	case "http":
+		proxy.Type = ProxyTypeHTTP
+		proxy.UDP = false
+		proxy.Username = jsonString(m, "username")
+		proxy.Password = jsonString(m, "password")
+	default:
+		return nil, fmt.Errorf("outbound %q has unsupported sing-box type %q", proxy.Name, outboundType)
+	}
+
+	if tls := jsonMap(m, "tls"); tls != nil && jsonBool(tls, "enabled") {
+		proxy.TLS = true
+		proxy.SNI = jsonString(tls, "server_name")
+		proxy.SkipCertVerify = jsonBool(tls, "insecure")
+		proxy.ALPN = jsonStrings(tls, "alpn")
+		proxy.DisableSNI = jsonBool(tls, "disable_sni")
+		if utls := jsonMap(tls, "utls"); utls != nil && jsonBool(utls, "enabled") {
+			proxy.ClientFingerprint = jsonString(utls, "fingerprint")
+		}
+		if reality := jsonMap(tls, "reality"); reality != nil && jsonBool(reality, "enabled") {
+			proxy.RealityOpts = &RealityOptions{
+				PublicKey: jsonString(reality, "public_key"),
+				ShortID:   jsonString(reality, "short_id"),
+			}
+		}
+	}
+
+	if transport := jsonMap(m, "transport"); transport != nil {
+		path := jsonString(transport, "path")
+		var host string
+		if hosts := jsonStrings(transport, "host"); len(hosts) > 0 {
+			host = hosts[0]
+		}
+		if headers := jsonMap(transport, "headers"); headers != nil && host == "" {
+			host = jsonString(headers, "Host")
+		}
+
+		switch network := jsonString(transport, "type"); network {
+		case "ws":
+			proxy.Network = network
+			proxy.WSPath = path
+		case "httpupgrade":
+			proxy.Network = network
+			proxy.HTTPUpgradePath = path
+		case "grpc":
+			proxy.Network = network
+			proxy.GRPCServiceName = jsonString(transport, "service_name")
+		case "http":
+			proxy.Network = "h2"
+			proxy.H2Opts = &H2Options{Host: jsonStrings(transport, "host"), Path: path}
+		default:
+			proxy.Network = network
+		}
+		if host != "" && proxy.Network != "h2" {
+			proxy.WSHeaders = map[string]string{"Host": host}
+		}
+	}
+
+	if proxy.Server == "" {
+		return nil, fmt.Errorf("outbound %q has no server", proxy.Name)
+	}
+
+	if proxy.Name == "" {
+		proxy.Name = fmt.Sprintf("%s:%d", proxy.Server, proxy.Port)
+	}
+
+	return proxy, nil
+}
+
+func (p *Proxy) ToSingBox() (map[string]interface{}, error) {
+	m := map[string]interface{}{
+		"tag":         p.Name,
+		"server":      p.Server,
+		"server_port": p.Port,
+	}
+
+	switch p.Type {
+	case ProxyTypeVMess:
+		m["type"] = "vmess"
+		m["uuid"] = p.UUID
+		m["security"] = p.Cipher
+		m["alter_id"] = p.AlterId
+	case ProxyTypeVLess:
+		m["type"] = "vless"
+		m["uuid"] = p.UUID
+		if p.Flow != "" {
+			m["flow"] = p.Flow
+		}
+	case ProxyTypeTrojan:
+		m["type"] = "trojan"
+		m["password"] = p.Password
+	case ProxyTypeSS:
+		m["type"] = "shadowsocks"
+		m["method"] = p.Cipher
+		m["password"] = p.Password
+		if p.Plugin != "" {
+			// sing-box only runs the two SIP003 plugins below itself.
+			plugin, opts := p.sip003Plugin()
+			if plugin != "obfs-local" && plugin != "v2ray-plugin" {
+				return nil, fmt.Errorf("cannot convert proxy %q to sing-box: %s plugin is not supported", p.Name, p.Plugin)
+			}
+			m["plugin"] = plugin
+			m["plugin_opts"] = opts
+		}
+	case ProxyTypeHysteria2:
+		m["type"] = "hysteria2"
+		m["password"] = p.Password
+		if p.Obfs != "" {
+			m["obfs"] = map[string]interface{}{"type": p.Obfs, "password": p.ObfsPassword}
+		}
+		if up := parseMbps(p.Up); up > 0 {
+			m["up_mbps"] = up
+		}
+		if down := parseMbps(p.Down); down > 0 {
+			m["down_mbps"] = down
+		}
+		if p.Ports != "" {
+			m["server_ports"] = strings.Split(strings.ReplaceAll(p.Ports, "-", ":"), ",")
+		}
+	case ProxyTypeTUIC:
+		m["type"] = "tuic"
+		m["uuid"] = p.UUID
+		m["password"] = p.Password
+		if p.CongestionControl != "" {
+			m["congestion_control"] = p.CongestionControl
+		}
+		if p.UDPRelayMode != "" {
+			m["udp_relay_mode"] = p.UDPRelayMode
+		}
+		if p.ReduceRTT {
+			m["zero_rtt_handshake"] = true
+		}
+	case ProxyTypeSocks5:
+		m["type"] = "socks"
+		if p.Username != "" {
+			m["username"] = p.Username
+			m["password"] = p.Password
+		}
+	case ProxyTypeHTTP:
+		m["type"] = "http"
+		if p.Username != "" {
+			m["username"] = p.Username
+			m["password"] = p.Password
+		}
+	default:
+		return nil, fmt.Errorf("cannot convert proxy %q to sing-box: unsupported type %q", p.Name, p.Type)
+	}
+
+	// hysteria2 and tuic are QUIC based and always carry TLS
+	if p.TLS || p.Type == ProxyTypeHysteria2 || p.Type == ProxyTypeTUIC {
+		tls := map[string]interface{}{"enabled": true}
+		if p.SNI != "" {
+			tls["server_name"] = p.SNI
+		}
+		if p.SkipCertVerify {
+			tls["insecure"] = true
+		}
+		if len(p.ALPN) > 0 {
+			tls["alpn"] = p.ALPN
+		}
+		if p.DisableSNI {
+			tls["disable_sni"] = true
+		}
+		if p.ClientFingerprint != "" {
+			tls["utls"] = map[string]interface{}{"enabled": true, "fingerprint": p.ClientFingerprint}
+		}
+		if p.RealityOpts != nil {
+			tls["reality"] = map[string]interface{}{
+				"enabled":    true,
+				"public_key": p.RealityOpts.PublicKey,
+				"short_id":   p.RealityOpts.ShortID,
+			}
+		}
+		m["tls"] = tls
+	}
+
+	host, path := p.transportHostPath()
+	switch p.Network {
+	case "ws", "websocket":
+		transport := map[string]interface{}{"type": "ws", "path": path}
+		if host != "" {
+			transport["headers"] = map[string]interface{}{"Host": host}
+		}
+		m["transport"] = transport
+	case "httpupgrade":
+		transport := map[string]interface{}{"type": "httpupgrade", "path": path}
+		if host != "" {
+			transport["host"] = host
+		}
+		m["transport"] = transport
+	case "grpc":
+		m["transport"] = map[string]interface{}{"type": "grpc", "service_name": path}
+	case "h2":
+		transport := map[string]interface{}{"type": "http", "path": path}
+		if p.H2Opts != nil && len(p.H2Opts.Host) > 0 {
+			transport["host"] = p.H2Opts.Host
+		}
+		m["transport"] = transport
+	case "", "tcp":
+	default:
+		return nil, fmt.Errorf("cannot convert proxy %q to sing-box: unsupported transport %q", p.Name, p.Network)
+	}
+
+	return m, nil
+}
+
+// parseMbps reads bandwidth hints such as "100" or "100 Mbps".
+func parseMbps(value string) int {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0
+	}
+	n, _ := strconv.Atoi(fields[0])
+	return n
+}
+
+func jsonString(m map[string]interface{}, key string) string {
+	return getStringValue(m[key])
+}
+
+func jsonInt(m map[string]interface{}, key string) int {
+	n, _ := strconv.Atoi(jsonString(m, key))
+	return n
+}
+
+func jsonBool(m map[string]interface{}, key string) bool {
+	switch v := m[key].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true" || v == "1"
+	case float64:
+		return v != 0
+	}
+	return false
+}
+
+func jsonMap(m map[string]interface{}, key string) map[string]interface{} {
+	if v, ok := m[key].(map[string]interface{}); ok {
+		return v
+	}
+	return nil
+}
+
+func jsonStrings(m map[string]interface{}, key string) []string {
+	switch v := m[key].(type) {
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			result = append(result, getStringValue(item))
+		}
+		return result
+	case string:
+		if v == "" {
+			return nil
+		}
+		return splitList(v)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type sip008Config struct {
+	Version int            `json:"version"`
+	Servers []sip008Server `json:"servers"`
+}
+
+type sip008Server struct {
+	ID         string `json:"id"`
+	Remarks    string `json:"remarks"`
+	Server     string `json:"server"`
+	ServerPort int    `json:"server_port"`
+	Password   string `json:"password"`
+	Method     string `json:"method"`
+	Plugin     string `json:"plugin"`
+	PluginOpts string `json:"plugin_opts"`
+}
+
+// IsSIP008JSON reports whether content is a SIP008 online configuration
+// ({"version": 1, "servers": [...]}).
+func IsSIP008JSON(content string) bool {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "{") {
+		return false
+	}
+
+	var config sip008Config
+	if err := json.Unmarshal([]byte(content), &config); err != nil {
+		return false
+	}
+	return len(config.Servers) > 0 && config.Servers[0].Server != ""
+}
+
+func ParseSIP008(data []byte) ([]*Proxy, error) {
+	var config sip008Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse sip008 config: %v", err)
+	}
+
+	var proxies []*Proxy
+	var skipped []string
+	for _, server := range config.Servers {
+		if server.Server == "" || server.Method == "" {
+			continue
+		}
+
+		plugin, pluginOpts, err := mihomoPlugin(server.Plugin, server.PluginOpts)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("server %q: %v", server.Remarks, err))
+			continue
+		}
+
+		proxy := &Proxy{
+			Name:       server.Remarks,
+			Type:       ProxyTypeSS,
+			Server:     server.Server,
+			Port:       server.ServerPort,
+			Password:   server.Password,
+			Cipher:     server.Method,
+			Plugin:     plugin,
+			PluginOpts: pluginOpts,
+			UDP:        true,
+		}
+
+		proxies = append(proxies, withDefaultName(proxy))
+	}
+
+	if len(proxies) == 0 {
+		if len(skipped) > 0 {
+			return nil, fmt.Errorf("sip008 config has no usable servers: %v", skipped)
+		}
+		return nil, fmt.Errorf("sip008 config has no usable servers")
+	}
+
+	return proxies, nil
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/url"
-	"strconv"
 	"strings"
 )
 
@@ -27,76 +26,50 @@ func ParseSS(link string) (*Proxy, error) {
 		link = link[:idx]
 	}
 
-	var method, password, server string
-	var port int
+	var userinfo, serverPort string
 
 	if strings.Contains(link, "@") {
-		parts := strings.Split(link, "@")
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid ss link format")
-		}
-
-		userinfo := parts[0]
-		serverPort := parts[1]
-
-		decoded, err := base64.RawURLEncoding.DecodeString(userinfo)
-		if err != nil {
-			decoded, err = base64.StdEncoding.DecodeString(userinfo)
+		// SIP002: base64 userinfo, or percent-encoded plain userinfo as
+		// used with the 2022 ciphers.
+		userinfo, serverPort, _ = splitUserinfo(link)
+
+		if unescaped, err := url.PathUnescape(userinfo); err == nil && strings.Contains(unescaped, ":") {
+			userinfo = unescaped
+		} else {
+			decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(userinfo, "="))
 			if err != nil {
-				return nil, fmt.Errorf("failed to decode userinfo: %v", err)
+				decoded, err = base64.StdEncoding.DecodeString(userinfo)
+				if err != nil {
+					return nil, parseError(ErrorDecode, "failed to decode userinfo: %v", err)
+				}
 			}
-		}
-
-		methodPassword := strings.SplitN(string(decoded), ":", 2)
-		if len(methodPassword) != 2 {
-			return nil, fmt.Errorf("invalid method:password format")
-		}
-
-		method = methodPassword[0]
-		password = methodPassword[1]
-
-		serverParts := strings.Split(serverPort, ":")
-		if len(serverParts) != 2 {
-			return nil, fmt.Errorf("invalid server:port format")
-		}
-
-		server = serverParts[0]
-		port, err = strconv.Atoi(serverParts[1])
-		if err != nil {
-			return nil, fmt.Errorf("invalid port: %v", err)
+			userinfo = string(decoded)
 		}
 	} else {
-		decoded, err := base64.RawURLEncoding.DecodeString(link)
+		decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(link, "="))
 		if err != nil {
 			decoded, err = base64.StdEncoding.DecodeString(link)
 			if err != nil {
-				return nil, fmt.Errorf("failed to decode ss link: %v", err)
+				return nil, parseError(ErrorDecode, "failed to decode ss link: %v", err)
 			}
 		}
 
-		parts := strings.SplitN(string(decoded), "@", 2)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid ss link format")
-		}
-
-		methodPassword := strings.SplitN(parts[0], ":", 2)
-		if len(methodPassword) != 2 {
-			return nil, fmt.Errorf("invalid method:password format")
+		var ok bool
+		userinfo, serverPort, ok = splitUserinfo(string(decoded))
+		if !ok {
+			return nil, parseError(ErrorMalformed, "invalid ss link format")
 		}
+	}
 
-		method = methodPassword[0]
-		password = methodPassword[1]
-
-		serverParts := strings.Split(parts[1], ":")
-		if len(serverParts) != 2 {
-			return nil, fmt.Errorf("invalid server:port format")
-		}
+	methodPassword := strings.SplitN(userinfo, ":", 2)
+	if len(methodPassword) != 2 {
+		return nil, parseError(ErrorMalformed, "invalid method:password format")
+	}
+	method, password := methodPassword[0], methodPassword[1]
 
-		server = serverParts[0]
-		port, err = strconv.Atoi(serverParts[1])
-		if err != nil {
-			return nil, fmt.Errorf("invalid port: %v", err)
-		}
+	server, port, err := splitEndpoint(serverPort)
+	if err != nil {
+		return nil, err
 	}
 
 	proxy := &Proxy{
@@ -113,18 +86,9 @@ func ParseSS(link string) (*Proxy, error) {
 		params, _ := url.ParseQuery(query)
 
 		if plugin := params.Get("plugin"); plugin != "" {
-			pluginParts := strings.SplitN(plugin, ";", 2)
-			proxy.Plugin = pluginParts[0]
-
-			if len(pluginParts) > 1 {
-				opts := make(map[string]interface{})
-				for _, opt := range strings.Split(pluginParts[1], ";") {
-					kv := strings.SplitN(opt, "=", 2)
-					if len(kv) == 2 {
-						opts[kv[0]] = kv[1]
-					}
-				}
-				proxy.PluginOpts = opts
+			proxy.Plugin, proxy.PluginOpts, err = parseSIP002Plugin(plugin)
+			if err != nil {
+				return nil, err
 			}
 		}
 	}
@@ -0,0 +1,89 @@
+package converter
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func ParseSSR(link string) (*Proxy, error) {
+	if !strings.HasPrefix(link, "ssr://") {
+		return nil, fmt.Errorf("invalid ssr link")
+	}
+
+	decoded, err := decodeBase64(strings.TrimPrefix(link, "ssr://"))
+	if err != nil {
+		return nil, parseError(ErrorDecode, "failed to decode ssr link: %v", err)
+	}
+
+	content := string(decoded)
+
+	var query string
+	if idx := strings.Index(content, "/?"); idx != -1 {
+		query = content[idx+2:]
+		content = content[:idx]
+	} else if idx := strings.Index(content, "?"); idx != -1 {
+		query = content[idx+1:]
+		content = content[:idx]
+	}
+
+	// server:port:protocol:method:obfs:password, where server may be an IPv6 literal
+	parts := strings.Split(content, ":")
+	if len(parts) < 6 {
+		return nil, parseError(ErrorMalformed, "invalid ssr link format")
+	}
+
+	n := len(parts)
+	server, err := normalizeHost(strings.Join(parts[:n-5], ":"))
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := strconv.Atoi(parts[n-5])
+	if err != nil {
+		return nil, parseError(ErrorBadPort, "invalid port: %v", err)
+	}
+
+	password, err := decodeBase64(parts[n-1])
+	if err != nil {
+		return nil, parseError(ErrorDecode, "failed to decode ssr password: %v", err)
+	}
+
+	proxy := &Proxy{
+		Type:     ProxyTypeSSR,
+		Server:   server,
+		Port:     port,
+		Protocol: parts[n-4],
+		Cipher:   parts[n-3],
+		Obfs:     parts[n-2],
+		Password: string(password),
+		UDP:      true,
+	}
+
+	if query != "" {
+		params, _ := url.ParseQuery(query)
+
+		proxy.ObfsParam = decodeSSRParam(params.Get("obfsparam"))
+		proxy.ProtocolParam = decodeSSRParam(params.Get("protoparam"))
+		proxy.Name = decodeSSRParam(params.Get("remarks"))
+	}
+
+	if proxy.Name == "" {
+		proxy.Name = fmt.Sprintf("%s:%d", server, port)
+	}
+
+	return proxy, nil
+}
+
+func decodeSSRParam(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	decoded, err := decodeBase64(strings.ReplaceAll(value, " ", "+"))
+	if err != nil {
+		return ""
+	}
+	return string(decoded)
+}
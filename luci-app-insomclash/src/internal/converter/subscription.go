@@ -3,73 +3,160 @@ package converter
 import (
 	"encoding/base64"
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
-	"time"
 )
 
-func FetchSubscription(url string) ([]*Proxy, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
+type SubscriptionFormat string
+
+const (
+	FormatLinks       SubscriptionFormat = "links"
+	FormatBase64      SubscriptionFormat = "base64"
+	FormatWireGuard   SubscriptionFormat = "wireguard"
+	FormatMihomo      SubscriptionFormat = "mihomo"
+	FormatSingBox     SubscriptionFormat = "sing-box"
+	FormatXray        SubscriptionFormat = "xray"
+	FormatSIP008      SubscriptionFormat = "sip008"
+	FormatSurge       SubscriptionFormat = "surge"
+	FormatQuantumultX SubscriptionFormat = "quantumultx"
+)
 
-	resp, err := client.Get(url)
+// ParseSubscription auto-detects the subscription format, parses it and
+// reports which format was found. Base64 wrapped content is reported as
+// base64 when it holds share links, otherwise as the wrapped format.
+func ParseSubscription(content string) ([]*Proxy, SubscriptionFormat, error) {
+	sub, err := ParseSubscriptionContent(content)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch subscription: %v", err)
+		return nil, sub.Format, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("subscription returned status %d", resp.StatusCode)
-	}
+	return sub.Proxies, sub.Format, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read subscription body: %v", err)
-	}
+// ParseSubscriptionContent is ParseSubscription with a report of how every
+// entry was parsed. The returned subscription is never nil, so the report is
+// available even when nothing could be parsed.
+func ParseSubscriptionContent(content string) (*Subscription, error) {
+	content = strings.TrimSpace(content)
 
-	decoded, err := base64.StdEncoding.DecodeString(string(body))
-	if err != nil {
-		decoded, err = base64.RawStdEncoding.DecodeString(string(body))
+	format := DetectFormat(content)
+	if format == "" {
+		decoded, err := base64.StdEncoding.DecodeString(content)
 		if err != nil {
-			decoded = body
+			decoded, err = base64.RawStdEncoding.DecodeString(content)
+		}
+		if err == nil {
+			content = strings.TrimSpace(string(decoded))
+			format = DetectFormat(content)
+			if format == "" || format == FormatLinks {
+				format = FormatBase64
+			}
+		} else {
+			format = FormatLinks
 		}
 	}
 
-	lines := strings.Split(string(decoded), "\n")
+	sub := &Subscription{Format: format}
+	var err error
 
-	var links []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+	switch format {
+	case FormatWireGuard:
+		var proxy *Proxy
+		proxy, err = ParseWireGuardConfig(content, "")
+		if err == nil {
+			sub.Proxies = []*Proxy{proxy}
+		}
+	case FormatMihomo:
+		sub.Proxies, err = ParseMihomoProxies([]byte(content))
+	case FormatSingBox, FormatXray:
+		sub.Proxies, err = ParseOutbounds([]byte(content))
+	case FormatSIP008:
+		sub.Proxies, err = ParseSIP008([]byte(content))
+	case FormatSurge:
+		sub.Proxies, sub.Report, err = parseLines(content, IsSurgeLine, surgeLineType, ParseSurgeLine)
+	case FormatQuantumultX:
+		sub.Proxies, sub.Report, err = parseLines(content, IsQuantumultXLine, quantumultXLineType, ParseQuantumultXLine)
+	default:
+		// Keep blank lines so result indexes match line numbers.
+		sub.Proxies, sub.Report = ParseLinksReport(strings.Split(content, "\n"))
+		if len(sub.Proxies) == 0 && sub.Report.Failed > 0 {
+			err = sub.Report.err("links")
 		}
-		links = append(links, line)
 	}
 
-	return ParseLinks(links)
-}
+	if sub.Report == nil {
+		sub.Report = summarizeProxies(sub.Proxies)
+	}
 
-func ParseSubscription(content string) ([]*Proxy, error) {
-	decoded, err := base64.StdEncoding.DecodeString(content)
 	if err != nil {
-		decoded, err = base64.RawStdEncoding.DecodeString(content)
-		if err != nil {
-			decoded = []byte(content)
+		sub.Proxies = nil
+		return sub, err
+	}
+
+	return sub, nil
+}
+
+// DetectFormat reports the format of plain (not base64 wrapped) content, or
+// an empty format when nothing is recognised.
+func DetectFormat(content string) SubscriptionFormat {
+	switch {
+	case IsWireGuardConfig(content):
+		return FormatWireGuard
+	case IsMihomoYAML(content):
+		return FormatMihomo
+	case IsOutboundsJSON(content):
+		if strings.Contains(content, `"protocol"`) {
+			return FormatXray
+		}
+		return FormatSingBox
+	case IsSIP008JSON(content):
+		return FormatSIP008
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case IsProxyLink(line):
+			return FormatLinks
+		case IsQuantumultXLine(line):
+			return FormatQuantumultX
+		case IsSurgeLine(line):
+			return FormatSurge
 		}
 	}
 
-	lines := strings.Split(string(decoded), "\n")
+	return ""
+}
+
+// parseLines parses every line accepted by match, so sections, comments and
+// proxy groups around the proxy definitions are ignored. typeOf names the
+// proxy type of a line for the report when it fails to parse.
+func parseLines(content string, match func(string) bool, typeOf func(string) string, parse func(string) (*Proxy, error)) ([]*Proxy, *ParseReport, error) {
+	var proxies []*Proxy
+	report := newParseReport()
 
-	var links []string
-	for _, line := range lines {
+	for i, line := range strings.Split(content, "\n") {
 		line = strings.TrimSpace(line)
-		if line == "" {
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || !match(line) {
 			continue
 		}
-		links = append(links, line)
+
+		proxy, err := parse(line)
+		if err == nil {
+			err = checkProxy(proxy)
+		}
+		report.add(i, line, typeOf(line), proxy, err)
+		if err != nil {
+			continue
+		}
+		proxies = append(proxies, proxy)
+	}
+
+	if len(proxies) == 0 {
+		if report.Failed > 0 {
+			return nil, report, report.err("proxies")
+		}
+		return nil, report, fmt.Errorf("no proxies found")
 	}
 
-	return ParseLinks(links)
+	return proxies, report, nil
 }
@@ -0,0 +1,217 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var surgeTypes = map[string]ProxyType{
+	"ss":         ProxyTypeSS,
+	"vmess":      ProxyTypeVMess,
+	"trojan":     ProxyTypeTrojan,
+	"http":       ProxyTypeHTTP,
+	"https":      ProxyTypeHTTP,
+	"socks5":     ProxyTypeSocks5,
+	"socks5-tls": ProxyTypeSocks5,
+	"hysteria2":  ProxyTypeHysteria2,
+	"tuic":       ProxyTypeTUIC,
+	"tuic-v5":    ProxyTypeTUIC,
+}
+
+// IsSurgeLine reports whether line is a Surge proxy definition such as
+// "name = vmess, example.com, 443, username=...".
+func IsSurgeLine(line string) bool {
+	idx := strings.Index(line, "=")
+	if idx == -1 {
+		return false
+	}
+
+	fields := splitFields(line[idx+1:])
+	if len(fields) < 3 {
+		return false
+	}
+	_, ok := surgeTypes[strings.ToLower(fields[0])]
+	return ok
+}
+
+// surgeLineType returns the proxy type a Surge line declares, or an empty
+// string.
+func surgeLineType(line string) string {
+	idx := strings.Index(line, "=")
+	if idx == -1 {
+		return ""
+	}
+
+	fields := splitFields(line[idx+1:])
+	if len(fields) == 0 {
+		return ""
+	}
+	return string(surgeTypes[strings.ToLower(fields[0])])
+}
+
+func ParseSurgeLine(line string) (*Proxy, error) {
+	idx := strings.Index(line, "=")
+	if idx == -1 {
+		return nil, parseError(ErrorMalformed, "invalid surge proxy line")
+	}
+
+	name := strings.TrimSpace(line[:idx])
+	fields := splitFields(line[idx+1:])
+	if len(fields) < 3 {
+		return nil, parseError(ErrorMalformed, "invalid surge proxy line")
+	}
+
+	server, err := normalizeHost(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, parseError(ErrorBadPort, "invalid port: %v", err)
+	}
+
+	proxy := &Proxy{
+		Name:   name,
+		Server: server,
+		Port:   port,
+	}
+
+	// Positional values after the port are username and password for
+	// http and socks5; everything else is key=value.
+	var positional []string
+	opts := make(map[string]string)
+	for _, field := range fields[3:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			positional = append(positional, field)
+			continue
+		}
+		opts[strings.ToLower(strings.TrimSpace(kv[0]))] = unquote(kv[1])
+	}
+
+	proxy.UDP = opts["udp-relay"] == "true"
+	proxy.SNI = opts["sni"]
+	proxy.SkipCertVerify = opts["skip-cert-verify"] == "true" || opts["skip-cert-verify"] == "1"
+
+	switch typ := strings.ToLower(fields[0]); typ {
+	case "ss":
+		proxy.Type = ProxyTypeSS
+		proxy.Cipher = opts["encrypt-method"]
+		proxy.Password = opts["password"]
+		if obfs := opts["obfs"]; obfs != "" {
+			proxy.Plugin = "obfs"
+			proxy.PluginOpts = map[string]interface{}{"mode": obfs}
+			if host := opts["obfs-host"]; host != "" {
+				proxy.PluginOpts["host"] = host
+			}
+		}
+	case "vmess":
+		proxy.Type = ProxyTypeVMess
+		proxy.UUID = opts["username"]
+		proxy.Cipher = opts["encrypt-method"]
+		if proxy.Cipher == "" {
+			proxy.Cipher = "auto"
+		}
+		proxy.TLS = opts["tls"] == "true"
+	case "trojan":
+		proxy.Type = ProxyTypeTrojan
+		proxy.Password = opts["password"]
+		proxy.TLS = true
+	case "http", "https":
+		proxy.Type = ProxyTypeHTTP
+		proxy.TLS = typ == "https"
+		proxy.Username, proxy.Password = surgeCredentials(positional, opts)
+	case "socks5", "socks5-tls":
+		proxy.Type = ProxyTypeSocks5
+		proxy.TLS = typ == "socks5-tls"
+		proxy.Username, proxy.Password = surgeCredentials(positional, opts)
+	case "hysteria2":
+		proxy.Type = ProxyTypeHysteria2
+		proxy.Password = opts["password"]
+		proxy.Down = opts["download-bandwidth"]
+		proxy.UDP = true
+	case "tuic", "tuic-v5":
+		proxy.Type = ProxyTypeTUIC
+		proxy.UUID = opts["uuid"]
+		proxy.Password = opts["password"]
+		if proxy.Password == "" {
+			proxy.Password = opts["token"]
+		}
+		if alpn := opts["alpn"]; alpn != "" {
+			proxy.ALPN = splitList(alpn)
+		}
+		proxy.UDP = true
+	}
+
+	if opts["ws"] == "true" {
+		proxy.Network = "ws"
+		proxy.WSPath = opts["ws-path"]
+		if headers := parseSurgeHeaders(opts["ws-headers"]); len(headers) > 0 {
+			proxy.WSHeaders = headers
+		}
+	}
+
+	if proxy.Name == "" {
+		proxy.Name = fmt.Sprintf("%s:%d", proxy.Server, proxy.Port)
+	}
+
+	return proxy, nil
+}
+
+func surgeCredentials(positional []string, opts map[string]string) (string, string) {
+	if len(positional) >= 2 {
+		return unquote(positional[0]), unquote(positional[1])
+	}
+	return opts["username"], opts["password"]
+}
+
+// parseSurgeHeaders reads "Host:example.com|User-Agent:foo".
+func parseSurgeHeaders(value string) map[string]string {
+	if value == "" {
+		return nil
+	}
+
+	headers := make(map[string]string)
+	for _, header := range strings.Split(value, "|") {
+		kv := strings.SplitN(header, ":", 2)
+		if len(kv) == 2 {
+			headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return headers
+}
+
+// splitFields splits a comma separated option list, keeping commas that are
+// inside double quotes.
+func splitFields(s string) []string {
+	var fields []string
+	var b strings.Builder
+	quoted := false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			b.WriteRune(r)
+		case r == ',' && !quoted:
+			fields = append(fields, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if last := strings.TrimSpace(b.String()); last != "" {
+		fields = append(fields, last)
+	}
+	return fields
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,115 @@
+package converter
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// xhttpModes are the xhttp upload modes understood by Xray and mihomo.
+var xhttpModes = map[string]bool{
+	"auto": true, "packet-up": true, "stream-up": true, "stream-one": true,
+}
+
+// xhttpExtraKeys maps keys of the Xray xhttp "extra" object that mihomo
+// supports to their xhttp-opts names. Other extra keys are kept for link and
+// Xray export only.
+var xhttpExtraKeys = [][2]string{
+	{"headers", "headers"},
+	{"noGRPCHeader", "no-grpc-header"},
+	{"xPaddingBytes", "x-padding-bytes"},
+	{"scMaxEachPostBytes", "sc-max-each-post-bytes"},
+}
+
+// applyTransportParams reads the transport of a vless or trojan link from
+// its query parameters, following the Xray share link format where "http"
+// names the HTTP/2 transport and "tcp" with headerType=http the HTTP header
+// obfuscation.
+func applyTransportParams(proxy *Proxy, params url.Values) error {
+	network := params.Get("type")
+	if network == "" {
+		return nil
+	}
+	proxy.Network = network
+
+	host := params.Get("host")
+	path, _ := url.QueryUnescape(params.Get("path"))
+
+	switch network {
+	case "ws", "websocket":
+		proxy.WSPath = path
+	case "grpc":
+		proxy.GRPCServiceName = params.Get("serviceName")
+	case "splithttp":
+		proxy.SplitHTTPPath = path
+	case "xhttp":
+		proxy.XHTTPPath = path
+		proxy.XHTTPMode = params.Get("mode")
+		extra, err := parseXHTTPExtra(params.Get("extra"))
+		if err != nil {
+			return err
+		}
+		proxy.XHTTPExtra = extra
+	case "httpupgrade":
+		proxy.HTTPUpgradePath = path
+	case "http", "h2":
+		proxy.Network = "h2"
+		proxy.H2Opts = newH2Options(host, path)
+		return nil
+	case "tcp", "raw":
+		proxy.Network = "tcp"
+		if params.Get("headerType") == "http" {
+			proxy.Network = "http"
+			proxy.HTTPOpts = newHTTPOptions(host, path)
+		}
+		return nil
+	}
+
+	if host != "" {
+		proxy.WSHeaders = map[string]string{"Host": host}
+	}
+	return nil
+}
+
+func newH2Options(host, path string) *H2Options {
+	return &H2Options{Host: splitList(host), Path: path}
+}
+
+func newHTTPOptions(host, path string) *HTTPOptions {
+	opts := &HTTPOptions{Path: splitList(path)}
+	if hosts := splitList(host); len(hosts) > 0 {
+		opts.Headers = map[string][]string{"Host": hosts}
+	}
+	return opts
+}
+
+// parseXHTTPExtra decodes the JSON "extra" object of an xhttp link.
+func parseXHTTPExtra(extra string) (map[string]interface{}, error) {
+	if extra == "" {
+		return nil, nil
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(extra), &result); err != nil {
+		return nil, parseError(ErrorDecode, "invalid xhttp extra: %v", err)
+	}
+	return result, nil
+}
+
+// h2HostPath returns the comma separated hosts and the path of the h2
+// transport.
+func (p *Proxy) h2HostPath() (string, string) {
+	if p.H2Opts == nil {
+		return "", ""
+	}
+	return strings.Join(p.H2Opts.Host, ","), p.H2Opts.Path
+}
+
+// httpHostPath returns the comma separated Host headers and paths of the
+// HTTP header obfuscation.
+func (p *Proxy) httpHostPath() (string, string) {
+	if p.HTTPOpts == nil {
+		return "", ""
+	}
+	return strings.Join(p.HTTPOpts.Headers["Host"], ","), strings.Join(p.HTTPOpts.Path, ",")
+}
@@ -3,7 +3,6 @@ package converter
 import (
 	"fmt"
 	"net/url"
-	"strconv"
 	"strings"
 )
 
@@ -26,23 +25,19 @@ func ParseTrojan(link string) (*Proxy, error) {
 		link = link[:idx]
 	}
 
-	parts := strings.Split(link, "@")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid trojan link format")
+	userinfo, serverPort, ok := splitUserinfo(link)
+	if !ok {
+		return nil, parseError(ErrorMalformed, "invalid trojan link format")
 	}
 
-	password := parts[0]
-	serverPort := parts[1]
-
-	serverParts := strings.Split(serverPort, ":")
-	if len(serverParts) != 2 {
-		return nil, fmt.Errorf("invalid server:port format")
+	password, err := url.PathUnescape(userinfo)
+	if err != nil {
+		return nil, parseError(ErrorDecode, "invalid trojan password: %v", err)
 	}
 
-	server := serverParts[0]
-	port, err := strconv.Atoi(serverParts[1])
+	server, port, err := splitEndpoint(serverPort)
 	if err != nil {
-		return nil, fmt.Errorf("invalid port: %v", err)
+		return nil, err
 	}
 
 	proxy := &Proxy{
@@ -69,38 +64,15 @@ func ParseTrojan(link string) (*Proxy, error) {
 			proxy.ALPN = strings.Split(alpn, ",")
 		}
 
-		if network := params.Get("type"); network != "" {
-			proxy.Network = network
-
-			switch network {
-			case "ws", "websocket":
-				if path := params.Get("path"); path != "" {
-					proxy.WSPath, _ = url.QueryUnescape(path)
-				}
-				if host := params.Get("host"); host != "" {
-					proxy.WSHeaders = map[string]string{"Host": host}
-				}
-			case "grpc":
-				if serviceName := params.Get("serviceName"); serviceName != "" {
-					proxy.GRPCServiceName = serviceName
-				}
-			case "splithttp":
-				if path := params.Get("path"); path != "" {
-					proxy.SplitHTTPPath, _ = url.QueryUnescape(path)
-				}
-			case "xhttp":
-				if path := params.Get("path"); path != "" {
-					proxy.XHTTPPath, _ = url.QueryUnescape(path)
-				}
-			case "httpupgrade":
-				if path := params.Get("path"); path != "" {
-					proxy.HTTPUpgradePath, _ = url.QueryUnescape(path)
-				}
-			}
+		if insecure := firstParam(params, "allowInsecure", "insecure"); insecure == "1" || insecure == "true" {
+			proxy.SkipCertVerify = true
 		}
 
-		if fp := params.Get("fp"); fp != "" {
+		if err := applyTransportParams(proxy, params); err != nil {
+			return nil, err
 		}
+
+		applyRealityParams(proxy, params)
 	}
 
 	if proxy.Name == "" {
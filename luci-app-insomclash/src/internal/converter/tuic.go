@@ -0,0 +1,98 @@
+package converter
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+func ParseTUIC(link string) (*Proxy, error) {
+	if !strings.HasPrefix(link, "tuic://") {
+		return nil, fmt.Errorf("invalid tuic link")
+	}
+
+	link = strings.TrimPrefix(link, "tuic://")
+
+	var remark string
+	if idx := strings.Index(link, "#"); idx != -1 {
+		remark, _ = url.QueryUnescape(link[idx+1:])
+		link = link[:idx]
+	}
+
+	var query string
+	if idx := strings.Index(link, "?"); idx != -1 {
+		query = link[idx+1:]
+		link = link[:idx]
+	}
+
+	link = strings.TrimSuffix(link, "/")
+
+	userinfo, serverPort, ok := splitUserinfo(link)
+	if !ok {
+		return nil, parseError(ErrorMalformed, "invalid tuic link format")
+	}
+
+	credentials := strings.SplitN(userinfo, ":", 2)
+	uuid, _ := url.PathUnescape(credentials[0])
+	if uuid == "" {
+		return nil, parseError(ErrorMissingField, "missing tuic uuid")
+	}
+
+	var password string
+	if len(credentials) == 2 {
+		password, _ = url.PathUnescape(credentials[1])
+	}
+
+	server, port, err := splitEndpoint(serverPort)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := &Proxy{
+		Name:     remark,
+		Type:     ProxyTypeTUIC,
+		Server:   server,
+		Port:     port,
+		UUID:     uuid,
+		Password: password,
+		UDP:      true,
+	}
+
+	if query != "" {
+		params, _ := url.ParseQuery(query)
+
+		if cc := firstParam(params, "congestion_control", "congestion-control", "congestion_controller"); cc != "" {
+			proxy.CongestionControl = cc
+		}
+
+		if mode := firstParam(params, "udp_relay_mode", "udp-relay-mode"); mode != "" {
+			proxy.UDPRelayMode = mode
+		}
+
+		if alpn := params.Get("alpn"); alpn != "" {
+			proxy.ALPN = strings.Split(alpn, ",")
+		}
+
+		if sni := params.Get("sni"); sni != "" {
+			proxy.SNI = sni
+		}
+
+		if insecure := firstParam(params, "allow_insecure", "insecure", "allowInsecure"); insecure == "1" || insecure == "true" {
+			proxy.SkipCertVerify = true
+		}
+
+		if disableSNI := params.Get("disable_sni"); disableSNI == "1" || disableSNI == "true" {
+			proxy.DisableSNI = true
+		}
+
+		if reduceRTT := params.Get("reduce_rtt"); reduceRTT == "1" || reduceRTT == "true" {
+			proxy.ReduceRTT = true
+		}
+	}
+
+	if proxy.Name == "" {
+		proxy.Name = fmt.Sprintf("%s:%d", server, port)
+	}
+
+	return proxy, nil
+}
@@ -3,34 +3,98 @@ package converter
 type ProxyType string
 
 const (
-	ProxyTypeVMess  ProxyType = "vmess"
-	ProxyTypeVLess  ProxyType = "vless"
-	ProxyTypeTrojan ProxyType = "trojan"
-	ProxyTypeSS     ProxyType = "ss"
+	ProxyTypeVMess     ProxyType = "vmess"
+	ProxyTypeVLess     ProxyType = "vless"
+	ProxyTypeTrojan    ProxyType = "trojan"
+	ProxyTypeSS        ProxyType = "ss"
+	ProxyTypeHysteria2 ProxyType = "hysteria2"
+	ProxyTypeTUIC      ProxyType = "tuic"
+	ProxyTypeWireGuard ProxyType = "wireguard"
+	ProxyTypeSSR       ProxyType = "ssr"
+	ProxyTypeSocks5    ProxyType = "socks5"
+	ProxyTypeHTTP      ProxyType = "http"
 )
 
 type Proxy struct {
-	Name            string                 `json:"name" yaml:"name"`
-	Type            ProxyType              `json:"type" yaml:"type"`
-	Server          string                 `json:"server" yaml:"server"`
-	Port            int                    `json:"port" yaml:"port"`
-	UUID            string                 `json:"uuid,omitempty" yaml:"uuid,omitempty"`
-	Password        string                 `json:"password,omitempty" yaml:"password,omitempty"`
-	Cipher          string                 `json:"cipher,omitempty" yaml:"cipher,omitempty"`
-	UDP             bool                   `json:"udp,omitempty" yaml:"udp,omitempty"`
-	TLS             bool                   `json:"tls,omitempty" yaml:"tls,omitempty"`
-	SNI             string                 `json:"sni,omitempty" yaml:"sni,omitempty"`
-	ALPN            []string               `json:"alpn,omitempty" yaml:"alpn,omitempty"`
-	Network         string                 `json:"network,omitempty" yaml:"network,omitempty"`
-	WSPath          string                 `json:"ws-path,omitempty" yaml:"ws-path,omitempty"`
-	WSHeaders       map[string]string      `json:"ws-headers,omitempty" yaml:"ws-headers,omitempty"`
-	GRPCServiceName string                 `json:"grpc-service-name,omitempty" yaml:"grpc-service-name,omitempty"`
-	SplitHTTPPath   string                 `json:"splithttp-path,omitempty" yaml:"splithttp-path,omitempty"`
-	XHTTPPath       string                 `json:"xhttp-path,omitempty" yaml:"xhttp-path,omitempty"`
-	HTTPUpgradePath string                 `json:"httpupgrade-path,omitempty" yaml:"httpupgrade-path,omitempty"`
-	Flow            string                 `json:"flow,omitempty" yaml:"flow,omitempty"`
-	AlterId         int                    `json:"alterId,omitempty" yaml:"alterId,omitempty"`
-	Plugin          string                 `json:"plugin,omitempty" yaml:"plugin,omitempty"`
-	PluginOpts      map[string]interface{} `json:"plugin-opts,omitempty" yaml:"plugin-opts,omitempty"`
-	SkipCertVerify  bool                   `json:"skip-cert-verify,omitempty" yaml:"skip-cert-verify,omitempty"`
+	Name              string                 `json:"name" yaml:"name"`
+	Type              ProxyType              `json:"type" yaml:"type"`
+	Server            string                 `json:"server" yaml:"server"`
+	Port              int                    `json:"port" yaml:"port"`
+	UUID              string                 `json:"uuid,omitempty" yaml:"uuid,omitempty"`
+	Username          string                 `json:"username,omitempty" yaml:"username,omitempty"`
+	Password          string                 `json:"password,omitempty" yaml:"password,omitempty"`
+	Cipher            string                 `json:"cipher,omitempty" yaml:"cipher,omitempty"`
+	UDP               bool                   `json:"udp,omitempty" yaml:"udp,omitempty"`
+	TLS               bool                   `json:"tls,omitempty" yaml:"tls,omitempty"`
+	SNI               string                 `json:"sni,omitempty" yaml:"sni,omitempty"`
+	ALPN              []string               `json:"alpn,omitempty" yaml:"alpn,omitempty"`
+	Network           string                 `json:"network,omitempty" yaml:"network,omitempty"`
+	WSPath            string                 `json:"ws-path,omitempty" yaml:"ws-path,omitempty"`
+	WSHeaders         map[string]string      `json:"ws-headers,omitempty" yaml:"ws-headers,omitempty"`
+	GRPCServiceName   string                 `json:"grpc-service-name,omitempty" yaml:"grpc-service-name,omitempty"`
+	SplitHTTPPath     string                 `json:"splithttp-path,omitempty" yaml:"splithttp-path,omitempty"`
+	XHTTPPath         string                 `json:"xhttp-path,omitempty" yaml:"xhttp-path,omitempty"`
+	XHTTPMode         string                 `json:"xhttp-mode,omitempty" yaml:"xhttp-mode,omitempty"`
+	XHTTPExtra        map[string]interface{} `json:"xhttp-extra,omitempty" yaml:"xhttp-extra,omitempty"`
+	H2Opts            *H2Options             `json:"h2-opts,omitempty" yaml:"h2-opts,omitempty"`
+	HTTPOpts          *HTTPOptions           `json:"http-opts,omitempty" yaml:"http-opts,omitempty"`
+	HTTPUpgradePath   string                 `json:"httpupgrade-path,omitempty" yaml:"httpupgrade-path,omitempty"`
+	Flow              string                 `json:"flow,omitempty" yaml:"flow,omitempty"`
+	AlterId           int                    `json:"alterId,omitempty" yaml:"alterId,omitempty"`
+	Plugin            string                 `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+	PluginOpts        map[string]interface{} `json:"plugin-opts,omitempty" yaml:"plugin-opts,omitempty"`
+	SkipCertVerify    bool                   `json:"skip-cert-verify,omitempty" yaml:"skip-cert-verify,omitempty"`
+	ClientFingerprint string                 `json:"client-fingerprint,omitempty" yaml:"client-fingerprint,omitempty"`
+	RealityOpts       *RealityOptions        `json:"reality-opts,omitempty" yaml:"reality-opts,omitempty"`
+	Ports             string                 `json:"ports,omitempty" yaml:"ports,omitempty"`
+	Obfs              string                 `json:"obfs,omitempty" yaml:"obfs,omitempty"`
+	ObfsPassword      string                 `json:"obfs-password,omitempty" yaml:"obfs-password,omitempty"`
+	ObfsParam         string                 `json:"obfs-param,omitempty" yaml:"obfs-param,omitempty"`
+	Protocol          string                 `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	ProtocolParam     string                 `json:"protocol-param,omitempty" yaml:"protocol-param,omitempty"`
+	Fingerprint       string                 `json:"fingerprint,omitempty" yaml:"fingerprint,omitempty"`
+	Up                string                 `json:"up,omitempty" yaml:"up,omitempty"`
+	Down              string                 `json:"down,omitempty" yaml:"down,omitempty"`
+	CongestionControl string                 `json:"congestion-controller,omitempty" yaml:"congestion-controller,omitempty"`
+	UDPRelayMode      string                 `json:"udp-relay-mode,omitempty" yaml:"udp-relay-mode,omitempty"`
+	DisableSNI        bool                   `json:"disable-sni,omitempty" yaml:"disable-sni,omitempty"`
+	ReduceRTT         bool                   `json:"reduce-rtt,omitempty" yaml:"reduce-rtt,omitempty"`
+	PrivateKey        string                 `json:"private-key,omitempty" yaml:"private-key,omitempty"`
+	PublicKey         string                 `json:"public-key,omitempty" yaml:"public-key,omitempty"`
+	PreSharedKey      string                 `json:"pre-shared-key,omitempty" yaml:"pre-shared-key,omitempty"`
+	IP                string                 `json:"ip,omitempty" yaml:"ip,omitempty"`
+	IPv6              string                 `json:"ipv6,omitempty" yaml:"ipv6,omitempty"`
+	AllowedIPs        []string               `json:"allowed-ips,omitempty" yaml:"allowed-ips,omitempty"`
+	Reserved          []int                  `json:"reserved,omitempty" yaml:"reserved,omitempty"`
+	MTU               int                    `json:"mtu,omitempty" yaml:"mtu,omitempty"`
+	Peers             []WireGuardPeer        `json:"peers,omitempty" yaml:"peers,omitempty"`
+	Extra             map[string]interface{} `json:"extra,omitempty" yaml:"-"`
+}
+
+type RealityOptions struct {
+	PublicKey string `json:"public-key" yaml:"public-key"`
+	ShortID   string `json:"short-id,omitempty" yaml:"short-id,omitempty"`
+	SpiderX   string `json:"spider-x,omitempty" yaml:"spider-x,omitempty"`
+}
+
+// H2Options configures the HTTP/2 transport (network h2).
+type H2Options struct {
+	Host []string `json:"host,omitempty" yaml:"host,omitempty"`
+	Path string   `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// HTTPOptions configures TCP with HTTP header obfuscation (network http).
+type HTTPOptions struct {
+	Method  string              `json:"method,omitempty" yaml:"method,omitempty"`
+	Path    []string            `json:"path,omitempty" yaml:"path,omitempty"`
+	Headers map[string][]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+type WireGuardPeer struct {
+	Server       string   `json:"server" yaml:"server"`
+	Port         int      `json:"port" yaml:"port"`
+	PublicKey    string   `json:"public-key" yaml:"public-key"`
+	PreSharedKey string   `json:"pre-shared-key,omitempty" yaml:"pre-shared-key,omitempty"`
+	Reserved     []int    `json:"reserved,omitempty" yaml:"reserved,omitempty"`
+	AllowedIPs   []string `json:"allowed-ips,omitempty" yaml:"allowed-ips,omitempty"`
 }
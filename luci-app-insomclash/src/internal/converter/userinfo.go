@@ -0,0 +1,137 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Subscription is a fetched subscription together with what the provider
+// told us about it and how its entries were parsed. ETag and LastModified
+// are the cache validators for the next conditional fetch.
+type Subscription struct {
+	Proxies      []*Proxy
+	Format       SubscriptionFormat
+	Info         *SubscriptionInfo
+	Report       *ParseReport
+	ETag         string
+	LastModified string
+	NotModified  bool
+}
+
+// SubscriptionInfo holds the quota and profile metadata airports send in the
+// Subscription-Userinfo, Profile-Update-Interval and Content-Disposition
+// headers. Traffic values are bytes, Expire is a unix timestamp and
+// UpdateInterval is in hours; zero means the provider did not report it.
+type SubscriptionInfo struct {
+	Upload         int64  `json:"upload"`
+	Download       int64  `json:"download"`
+	Total          int64  `json:"total"`
+	Expire         int64  `json:"expire,omitempty"`
+	UpdateInterval int    `json:"update_interval,omitempty"`
+	Name           string `json:"name,omitempty"`
+	UpdatedAt      int64  `json:"updated_at"`
+	ETag           string `json:"etag,omitempty"`
+	LastModified   string `json:"last_modified,omitempty"`
+}
+
+// ParseSubscriptionInfo reads subscription metadata from response headers.
+// It returns nil when none of the headers are present.
+func ParseSubscriptionInfo(header http.Header) *SubscriptionInfo {
+	userinfo := header.Get("Subscription-Userinfo")
+	interval := header.Get("Profile-Update-Interval")
+	name := contentDispositionName(header.Get("Content-Disposition"))
+
+	if userinfo == "" && interval == "" && name == "" {
+		return nil
+	}
+
+	info := &SubscriptionInfo{
+		Name:      name,
+		UpdatedAt: time.Now().Unix(),
+	}
+
+	for _, field := range strings.Split(userinfo, ";") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		// Some providers send floats such as "1.5e+10".
+		value, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			continue
+		}
+
+		switch strings.ToLower(kv[0]) {
+		case "upload":
+			info.Upload = int64(value)
+		case "download":
+			info.Download = int64(value)
+		case "total":
+			info.Total = int64(value)
+		case "expire":
+			info.Expire = int64(value)
+		}
+	}
+
+	if interval != "" {
+		info.UpdateInterval, _ = strconv.Atoi(strings.TrimSpace(interval))
+	}
+
+	return info
+}
+
+func contentDispositionName(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	// mime handles the RFC 5987 filename*=UTF-8''... form as well.
+	_, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		return ""
+	}
+
+	name := params["filename"]
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// SubscriptionInfoPath returns the metadata file stored next to a provider
+// file, e.g. proxy_providers/airport.info.json for airport.yaml.
+func SubscriptionInfoPath(providerPath string) string {
+	return strings.TrimSuffix(providerPath, filepath.Ext(providerPath)) + ".info.json"
+}
+
+func SaveSubscriptionInfo(providerPath string, info *SubscriptionInfo) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal subscription info: %v", err)
+	}
+
+	if err := os.WriteFile(SubscriptionInfoPath(providerPath), data, 0644); err != nil {
+		return fmt.Errorf("failed to write subscription info: %v", err)
+	}
+
+	return nil
+}
+
+func LoadSubscriptionInfo(providerPath string) (*SubscriptionInfo, error) {
+	data, err := os.ReadFile(SubscriptionInfoPath(providerPath))
+	if err != nil {
+		return nil, err
+	}
+
+	var info SubscriptionInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse subscription info: %v", err)
+	}
+
+	return &info, nil
+}
@@ -0,0 +1,460 @@
+package converter
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type Severity string
+
+const (
+	// SeverityError marks values mihomo refuses to load; one such proxy
+	// makes the whole provider fail.
+	SeverityError Severity = "error"
+	// SeverityWarning marks values mihomo accepts but that are unlikely to
+	// work or are ignored.
+	SeverityWarning Severity = "warning"
+)
+
+// ValidationIssue is a single problem found by Proxy.Validate.
+type ValidationIssue struct {
+	Field    string   `json:"field" example:"uuid"`
+	Severity Severity `json:"severity" example:"error"`
+	Message  string   `json:"message"`
+}
+
+// ProxyValidation lists the issues of the proxy at Index.
+type ProxyValidation struct {
+	Index  int               `json:"index"`
+	Name   string            `json:"name"`
+	Type   ProxyType         `json:"type"`
+	Issues []ValidationIssue `json:"issues"`
+}
+
+// ValidationReport summarises the validation of a proxy list. Errors counts
+// proxies with at least one error, Warnings proxies with only warnings.
+type ValidationReport struct {
+	Errors   int               `json:"errors"`
+	Warnings int               `json:"warnings"`
+	Proxies  []ProxyValidation `json:"proxies,omitempty"`
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+var vmessCiphers = map[string]bool{
+	"": true, "auto": true, "none": true, "zero": true,
+	"aes-128-gcm": true, "chacha20-poly1305": true,
+}
+
+// ssCiphers are the AEAD and 2022 ciphers mihomo supports.
+var ssCiphers = map[string]bool{
+	"none": true, "aes-128-gcm": true, "aes-192-gcm": true, "aes-256-gcm": true,
+	"aes-128-ccm": true, "aes-192-ccm": true, "aes-256-ccm": true,
+	"aes-128-gcm-siv": true, "aes-256-gcm-siv": true,
+	"chacha20-ietf-poly1305": true, "xchacha20-ietf-poly1305": true,
+	"chacha8-ietf-poly1305": true, "xchacha8-ietf-poly1305": true,
+	"lea-128-gcm": true, "lea-192-gcm": true, "lea-256-gcm": true,
+	"rabbit128-poly1305": true, "aegis-128l": true, "aegis-256": true,
+	"aez-384": true, "deoxys-ii-256-128": true,
+	"2022-blake3-aes-128-gcm": true, "2022-blake3-aes-256-gcm": true, "2022-blake3-chacha20-poly1305": true,
+}
+
+// ssStreamCiphers are still supported by mihomo but insecure and rejected by
+// most current servers.
+var ssStreamCiphers = map[string]bool{
+	"aes-128-cfb": true, "aes-192-cfb": true, "aes-256-cfb": true,
+	"aes-128-ctr": true, "aes-192-ctr": true, "aes-256-ctr": true,
+	"rc4-md5": true, "chacha20": true, "chacha20-ietf": true, "xchacha20": true,
+}
+
+var ssPlugins = map[string]bool{
+	"": true, "obfs": true, "v2ray-plugin": true, "gost-plugin": true,
+	"shadow-tls": true, "restls": true, "kcptun": true,
+}
+
+var ssrObfs = map[string]bool{
+	"plain": true, "http_simple": true, "http_post": true, "random_head": true,
+	"tls1.2_ticket_auth": true, "tls1.2_ticket_fastauth": true,
+}
+
+var ssrProtocols = map[string]bool{
+	"origin": true, "auth_sha1_v4": true, "auth_aes128_md5": true, "auth_aes128_sha1": true,
+	"auth_chain_a": true, "auth_chain_b": true,
+}
+
+var vlessFlows = map[string]bool{
+	"": true, "xtls-rprx-vision": true, "xtls-rprx-vision-udp443": true,
+}
+
+var tuicCongestionControls = map[string]bool{
+	"": true, "cubic": true, "new_reno": true, "bbr": true,
+}
+
+// transportTypes are the proxy types that honour network.
+var transportTypes = map[ProxyType]bool{
+	ProxyTypeVMess: true, ProxyTypeVLess: true, ProxyTypeTrojan: true,
+}
+
+type validator struct {
+	issues []ValidationIssue
+}
+
+func (v *validator) error(field, format string, args ...interface{}) {
+	v.issues = append(v.issues, ValidationIssue{Field: field, Severity: SeverityError, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warn(field, format string, args ...interface{}) {
+	v.issues = append(v.issues, ValidationIssue{Field: field, Severity: SeverityWarning, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks the proxy against what mihomo accepts for its type and
+// returns the issues found, or nil when there are none.
+func (p *Proxy) Validate() []ValidationIssue {
+	v := &validator{}
+
+	if strings.TrimSpace(p.Name) == "" {
+		v.error("name", "name is required")
+	}
+	if p.Server == "" {
+		v.error("server", "server is required")
+	} else if strings.ContainsAny(p.Server, " /?#@") {
+		v.error("server", "invalid server %q", p.Server)
+	}
+	if p.Port < 1 || p.Port > 65535 {
+		v.error("port", "port %d is outside 1-65535", p.Port)
+	}
+
+	switch p.Type {
+	case ProxyTypeVMess:
+		v.uuid(p.UUID, true)
+		if !vmessCiphers[p.Cipher] {
+			v.error("cipher", "unsupported vmess cipher %q", p.Cipher)
+		}
+		if p.AlterId > 0 {
+			v.warn("alterId", "alterId %d uses legacy vmess MD5 authentication", p.AlterId)
+		}
+	case ProxyTypeVLess:
+		v.uuid(p.UUID, true)
+		if !vlessFlows[p.Flow] {
+			v.error("flow", "unsupported vless flow %q", p.Flow)
+		} else if p.Flow != "" && !p.TLS {
+			v.error("flow", "flow %s requires tls", p.Flow)
+		}
+	case ProxyTypeTrojan:
+		if p.Password == "" {
+			v.error("password", "password is required")
+		}
+	case ProxyTypeSS:
+		p.validateSS(v)
+	case ProxyTypeSSR:
+		if !ssCiphers[p.Cipher] && !ssStreamCiphers[p.Cipher] {
+			v.error("cipher", "unsupported ssr cipher %q", p.Cipher)
+		}
+		if p.Password == "" {
+			v.error("password", "password is required")
+		}
+		if !ssrObfs[p.Obfs] {
+			v.error("obfs", "unsupported ssr obfs %q", p.Obfs)
+		}
+		if !ssrProtocols[p.Protocol] {
+			v.error("protocol", "unsupported ssr protocol %q", p.Protocol)
+		}
+	case ProxyTypeHysteria2:
+		if p.Password == "" {
+			v.warn("password", "password is empty")
+		}
+		switch p.Obfs {
+		case "":
+		case "salamander":
+			if p.ObfsPassword == "" {
+				v.error("obfs-password", "salamander obfs requires obfs-password")
+			}
+		default:
+			v.error("obfs", "unsupported hysteria2 obfs %q", p.Obfs)
+		}
+	case ProxyTypeTUIC:
+		v.uuid(p.UUID, false)
+		if p.Password == "" {
+			v.error("password", "password is required")
+		}
+		if !tuicCongestionControls[p.CongestionControl] {
+			v.error("congestion-controller", "unsupported congestion controller %q", p.CongestionControl)
+		}
+		switch p.UDPRelayMode {
+		case "", "native", "quic":
+		default:
+			v.error("udp-relay-mode", "unsupported udp relay mode %q", p.UDPRelayMode)
+		}
+	case ProxyTypeWireGuard:
+		p.validateWireGuard(v)
+	case ProxyTypeSocks5, ProxyTypeHTTP:
+		if p.Username != "" && p.Password == "" {
+			v.warn("password", "username is set without a password")
+		}
+	default:
+		// Passthrough types are written back to mihomo as they were read, so
+		// only the common fields above are checked.
+		if !passthroughTypes[p.Type] {
+			v.error("type", "unsupported proxy type %q", p.Type)
+		}
+	}
+
+	p.validateTransport(v)
+
+	if p.RealityOpts != nil {
+		if !p.TLS {
+			v.error("tls", "reality requires tls")
+		}
+		if p.RealityOpts.PublicKey == "" {
+			v.error("reality-opts.public-key", "reality requires a public key")
+		} else if !isX25519Key(p.RealityOpts.PublicKey) {
+			v.error("reality-opts.public-key", "invalid reality public key")
+		}
+		if id := p.RealityOpts.ShortID; id != "" {
+			if _, err := hex.DecodeString(id); err != nil || len(id) > 16 {
+				v.error("reality-opts.short-id", "short-id must be up to 16 hex characters")
+			}
+		}
+	}
+
+	return v.issues
+}
+
+// uuid checks a vmess, vless or tuic user id. mihomo maps non-UUID strings of
+// up to 30 bytes to a UUIDv5 like Xray does, except for tuic.
+func (v *validator) uuid(id string, mapped bool) {
+	switch {
+	case uuidPattern.MatchString(id):
+	case id == "":
+		v.error("uuid", "uuid is required")
+	case mapped && len(id) <= 30:
+		v.warn("uuid", "uuid is not a UUID and will be mapped to a UUIDv5")
+	default:
+		v.error("uuid", "invalid uuid")
+	}
+}
+
+func (p *Proxy) validateSS(v *validator) {
+	switch {
+	case ssStreamCiphers[p.Cipher]:
+		v.warn("cipher", "stream cipher %s is insecure", p.Cipher)
+	case !ssCiphers[p.Cipher]:
+		v.error("cipher", "unsupported ss cipher %q", p.Cipher)
+	}
+
+	if p.Password == "" && p.Cipher != "none" {
+		v.error("password", "password is required")
+	}
+
+	// 2022 ciphers take base64 keys of the cipher's key size, with an
+	// optional identity key per relay separated by colons.
+	if strings.HasPrefix(p.Cipher, "2022-") && p.Password != "" {
+		size := 32
+		if p.Cipher == "2022-blake3-aes-128-gcm" {
+			size = 16
+		}
+		for _, key := range strings.Split(p.Password, ":") {
+			decoded, err := base64.StdEncoding.DecodeString(key)
+			if err != nil || len(decoded) != size {
+				v.error("password", "%s needs base64 encoded %d byte keys", p.Cipher, size)
+				break
+			}
+		}
+	}
+
+	if !ssPlugins[p.Plugin] {
+		v.error("plugin", "unsupported ss plugin %q", p.Plugin)
+	}
+
+	switch p.Plugin {
+	case "obfs":
+		if mode := pluginOptString(p.PluginOpts, "mode"); mode != "http" && mode != "tls" {
+			v.error("plugin-opts.mode", "obfs mode must be http or tls")
+		}
+	case "v2ray-plugin":
+		if mode := pluginOptString(p.PluginOpts, "mode"); mode != "websocket" {
+			v.error("plugin-opts.mode", "v2ray-plugin mode must be websocket")
+		}
+	case "shadow-tls":
+		version := pluginOptString(p.PluginOpts, "version")
+		if version != "" && version != "1" && version != "2" && version != "3" {
+			v.error("plugin-opts.version", "shadow-tls version must be 1, 2 or 3")
+		}
+		if version != "1" && pluginOptString(p.PluginOpts, "password") == "" {
+			v.error("plugin-opts.password", "shadow-tls v2 and v3 need a password")
+		}
+	case "restls":
+		for _, key := range []string{"host", "password", "version-hint"} {
+			if pluginOptString(p.PluginOpts, key) == "" {
+				v.error("plugin-opts."+key, "restls needs %s", key)
+			}
+		}
+	}
+}
+
+func (p *Proxy) validateWireGuard(v *validator) {
+	if !isX25519Key(p.PrivateKey) {
+		v.error("private-key", "private-key must be a base64 encoded 32 byte key")
+	}
+
+	if len(p.Peers) == 0 {
+		if !isX25519Key(p.PublicKey) {
+			v.error("public-key", "public-key must be a base64 encoded 32 byte key")
+		}
+	}
+	for i, peer := range p.Peers {
+		if !isX25519Key(peer.PublicKey) {
+			v.error(fmt.Sprintf("peers[%d].public-key", i), "public-key must be a base64 encoded 32 byte key")
+		}
+	}
+
+	if p.PreSharedKey != "" && !isX25519Key(p.PreSharedKey) {
+		v.error("pre-shared-key", "pre-shared-key must be a base64 encoded 32 byte key")
+	}
+
+	if p.IP == "" && p.IPv6 == "" {
+		v.error("ip", "ip or ipv6 is required")
+	}
+
+	if len(p.Reserved) != 0 && len(p.Reserved) != 3 {
+		v.error("reserved", "reserved must have 3 values")
+	}
+	for _, value := range p.Reserved {
+		if value < 0 || value > 255 {
+			v.error("reserved", "reserved value %d is outside 0-255", value)
+			break
+		}
+	}
+}
+
+func (p *Proxy) validateTransport(v *validator) {
+	network := strings.ToLower(p.Network)
+	if network == "" || network == "tcp" {
+		return
+	}
+
+	if !transportTypes[p.Type] {
+		v.warn("network", "network %s is ignored for %s", p.Network, p.Type)
+		return
+	}
+
+	if !supportedNetworks[network] {
+		v.error("network", "unsupported transport %q", p.Network)
+		return
+	}
+
+	switch network {
+	case "grpc":
+		if p.GRPCServiceName == "" {
+			v.error("grpc-service-name", "grpc requires a service name")
+		}
+	case "ws", "websocket":
+		if p.WSPath != "" && !strings.HasPrefix(p.WSPath, "/") {
+			v.warn("ws-path", "ws path %q should start with /", p.WSPath)
+		}
+	case "h2":
+		if !p.TLS {
+			v.error("tls", "h2 requires tls")
+		}
+	case "xhttp":
+		if p.XHTTPMode != "" && !xhttpModes[p.XHTTPMode] {
+			v.error("xhttp-mode", "unsupported xhttp mode %q", p.XHTTPMode)
+		}
+	}
+}
+
+// isX25519Key reports whether key is a base64 encoded 32 byte key, as used
+// by WireGuard and REALITY.
+func isX25519Key(key string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		decoded, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(key, "="))
+	}
+	return err == nil && len(decoded) == 32
+}
+
+func hasErrors(issues []ValidationIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateProxies validates every proxy and reports the ones with issues.
+func ValidateProxies(proxies []*Proxy) *ValidationReport {
+	report := &ValidationReport{}
+
+	for i, proxy := range proxies {
+		issues := proxy.Validate()
+		if len(issues) == 0 {
+			continue
+		}
+
+		if hasErrors(issues) {
+			report.Errors++
+		} else {
+			report.Warnings++
+		}
+
+		report.Proxies = append(report.Proxies, ProxyValidation{
+			Index:  i,
+			Name:   proxy.Name,
+			Type:   proxy.Type,
+			Issues: issues,
+		})
+	}
+
+	return report
+}
+
+// DropInvalid returns the proxies without validation errors together with
+// the report of the full list.
+func DropInvalid(proxies []*Proxy) ([]*Proxy, *ValidationReport) {
+	report := ValidateProxies(proxies)
+	if report.Errors == 0 {
+		return proxies, report
+	}
+
+	invalid := make(map[int]bool)
+	for _, result := range report.Proxies {
+		if hasErrors(result.Issues) {
+			invalid[result.Index] = true
+		}
+	}
+
+	var valid []*Proxy
+	for i, proxy := range proxies {
+		if !invalid[i] {
+			valid = append(valid, proxy)
+		}
+	}
+	return valid, report
+}
+
+// Err describes the first few invalid proxies, for callers that reject a
+// list outright. It returns nil when no proxy has errors.
+func (r *ValidationReport) Err() error {
+	if r.Errors == 0 {
+		return nil
+	}
+
+	var parts []string
+	for _, result := range r.Proxies {
+		for _, issue := range result.Issues {
+			if issue.Severity == SeverityError {
+				parts = append(parts, fmt.Sprintf("%s: %s", result.Name, issue.Message))
+				break
+			}
+		}
+		if len(parts) == 3 {
+			break
+		}
+	}
+
+	return fmt.Errorf("%d invalid proxies: %s", r.Errors, strings.Join(parts, "; "))
+}
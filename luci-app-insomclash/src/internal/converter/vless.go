@@ -3,7 +3,6 @@ package converter
 import (
 	"fmt"
 	"net/url"
-	"strconv"
 	"strings"
 )
 
@@ -26,23 +25,19 @@ func ParseVLess(link string) (*Proxy, error) {
 		link = link[:idx]
 	}
 
-	parts := strings.Split(link, "@")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid vless link format")
+	userinfo, serverPort, ok := splitUserinfo(link)
+	if !ok {
+		return nil, parseError(ErrorMalformed, "invalid vless link format")
 	}
 
-	uuid := parts[0]
-	serverPort := parts[1]
-
-	serverParts := strings.Split(serverPort, ":")
-	if len(serverParts) != 2 {
-		return nil, fmt.Errorf("invalid server:port format")
+	uuid, err := url.PathUnescape(userinfo)
+	if err != nil {
+		return nil, parseError(ErrorDecode, "invalid vless uuid: %v", err)
 	}
 
-	server := serverParts[0]
-	port, err := strconv.Atoi(serverParts[1])
+	server, port, err := splitEndpoint(serverPort)
 	if err != nil {
-		return nil, fmt.Errorf("invalid port: %v", err)
+		return nil, err
 	}
 
 	proxy := &Proxy{
@@ -61,6 +56,8 @@ func ParseVLess(link string) (*Proxy, error) {
 			proxy.TLS = true
 		}
 
+		applyRealityParams(proxy, params)
+
 		if sni := params.Get("sni"); sni != "" {
 			proxy.SNI = sni
 		}
@@ -69,38 +66,16 @@ func ParseVLess(link string) (*Proxy, error) {
 			proxy.ALPN = strings.Split(alpn, ",")
 		}
 
+		if insecure := firstParam(params, "allowInsecure", "insecure"); insecure == "1" || insecure == "true" {
+			proxy.SkipCertVerify = true
+		}
+
 		if flow := params.Get("flow"); flow != "" {
 			proxy.Flow = flow
 		}
 
-		if network := params.Get("type"); network != "" {
-			proxy.Network = network
-
-			switch network {
-			case "ws", "websocket":
-				if path := params.Get("path"); path != "" {
-					proxy.WSPath, _ = url.QueryUnescape(path)
-				}
-				if host := params.Get("host"); host != "" {
-					proxy.WSHeaders = map[string]string{"Host": host}
-				}
-			case "grpc":
-				if serviceName := params.Get("serviceName"); serviceName != "" {
-					proxy.GRPCServiceName = serviceName
-				}
-			case "splithttp":
-				if path := params.Get("path"); path != "" {
-					proxy.SplitHTTPPath, _ = url.QueryUnescape(path)
-				}
-			case "xhttp":
-				if path := params.Get("path"); path != "" {
-					proxy.XHTTPPath, _ = url.QueryUnescape(path)
-				}
-			case "httpupgrade":
-				if path := params.Get("path"); path != "" {
-					proxy.HTTPUpgradePath, _ = url.QueryUnescape(path)
-				}
-			}
+		if err := applyTransportParams(proxy, params); err != nil {
+			return nil, err
 		}
 	}
 
@@ -110,3 +85,20 @@ func ParseVLess(link string) (*Proxy, error) {
 
 	return proxy, nil
 }
+
+func applyRealityParams(proxy *Proxy, params url.Values) {
+	if fp := params.Get("fp"); fp != "" && fp != "none" {
+		proxy.ClientFingerprint = fp
+	}
+
+	if params.Get("security") != "reality" {
+		return
+	}
+
+	proxy.TLS = true
+	proxy.RealityOpts = &RealityOptions{
+		PublicKey: params.Get("pbk"),
+		ShortID:   params.Get("sid"),
+		SpiderX:   params.Get("spx"),
+	}
+}
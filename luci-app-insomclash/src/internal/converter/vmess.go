@@ -54,19 +54,24 @@ func ParseVMess(link string) (*Proxy, error) {
 	if err != nil {
 		decoded, err = base64.StdEncoding.DecodeString(encoded)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode vmess link: %v", err)
+			return nil, parseError(ErrorDecode, "failed to decode vmess link: %v", err)
 		}
 	}
 
 	var config vmessConfig
 	if err := json.Unmarshal(decoded, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse vmess config: %v", err)
+		return nil, parseError(ErrorDecode, "failed to parse vmess config: %v", err)
+	}
+
+	server, err := normalizeHost(config.Add)
+	if err != nil {
+		return nil, err
 	}
 
 	portStr := getStringValue(config.Port)
 	port, err := strconv.Atoi(portStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid port: %v", err)
+		return nil, parseError(ErrorBadPort, "invalid port: %v", err)
 	}
 
 	alterId, _ := strconv.Atoi(getStringValue(config.Aid))
@@ -79,7 +84,7 @@ func ParseVMess(link string) (*Proxy, error) {
 	proxy := &Proxy{
 		Name:    config.PS,
 		Type:    ProxyTypeVMess,
-		Server:  config.Add,
+		Server:  server,
 		Port:    port,
 		UUID:    config.ID,
 		AlterId: alterId,
@@ -95,44 +100,62 @@ func ParseVMess(link string) (*Proxy, error) {
 		proxy.SNI = config.SNI
 	}
 
+	if config.FP != "" && config.FP != "none" {
+		proxy.ClientFingerprint = config.FP
+	}
+
 	alpnStr := getStringValue(config.ALPN)
 	if alpnStr != "" {
 		proxy.ALPN = strings.Split(alpnStr, ",")
 	}
 
-	if config.Net != "" {
-		proxy.Network = config.Net
-
-		switch config.Net {
-		case "ws", "websocket":
-			if config.Path != "" {
-				proxy.WSPath = config.Path
-			}
-			if config.Host != "" {
-				proxy.WSHeaders = map[string]string{"Host": config.Host}
-			}
-		case "grpc":
-			if config.Path != "" {
-				proxy.GRPCServiceName = config.Path
-			}
-		case "splithttp":
-			if config.Path != "" {
-				proxy.SplitHTTPPath = config.Path
-			}
-		case "xhttp":
-			if config.Path != "" {
-				proxy.XHTTPPath = config.Path
-			}
-		case "httpupgrade":
-			if config.Path != "" {
-				proxy.HTTPUpgradePath = config.Path
-			}
-		}
-	}
+	applyVMessTransport(proxy, &config)
 
 	if proxy.Name == "" {
-		proxy.Name = fmt.Sprintf("%s:%d", config.Add, port)
+		proxy.Name = fmt.Sprintf("%s:%d", server, port)
 	}
 
 	return proxy, nil
 }
+
+// applyVMessTransport reads the transport of a v2rayN vmess link. There
+// "type" is the header type of tcp and the mode of xhttp, while host and
+// path may hold comma separated lists.
+func applyVMessTransport(proxy *Proxy, config *vmessConfig) {
+	if config.Net == "" {
+		return
+	}
+	proxy.Network = config.Net
+
+	switch config.Net {
+	case "ws", "websocket":
+		proxy.WSPath = config.Path
+	case "grpc":
+		proxy.GRPCServiceName = config.Path
+	case "splithttp":
+		proxy.SplitHTTPPath = config.Path
+	case "xhttp":
+		proxy.XHTTPPath = config.Path
+		proxy.XHTTPMode = config.Mode
+		if xhttpModes[config.Type] {
+			proxy.XHTTPMode = config.Type
+		}
+	case "httpupgrade":
+		proxy.HTTPUpgradePath = config.Path
+	case "h2", "http":
+		proxy.Network = "h2"
+		proxy.H2Opts = newH2Options(config.Host, config.Path)
+		return
+	case "tcp", "raw":
+		proxy.Network = "tcp"
+		if config.Type == "http" {
+			proxy.Network = "http"
+			proxy.HTTPOpts = newHTTPOptions(config.Host, config.Path)
+		}
+		return
+	}
+
+	if config.Host != "" {
+		proxy.WSHeaders = map[string]string{"Host": config.Host}
+	}
+}
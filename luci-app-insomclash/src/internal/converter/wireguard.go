@@ -0,0 +1,273 @@
+package converter
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func ParseWireGuard(link string) (*Proxy, error) {
+	var rest string
+	if strings.HasPrefix(link, "wireguard://") {
+		rest = strings.TrimPrefix(link, "wireguard://")
+	} else if strings.HasPrefix(link, "wg://") {
+		rest = strings.TrimPrefix(link, "wg://")
+	} else {
+		return nil, fmt.Errorf("invalid wireguard link")
+	}
+
+	var remark string
+	if idx := strings.Index(rest, "#"); idx != -1 {
+		remark, _ = url.QueryUnescape(rest[idx+1:])
+		rest = rest[:idx]
+	}
+
+	var query string
+	if idx := strings.Index(rest, "?"); idx != -1 {
+		query = rest[idx+1:]
+		rest = rest[:idx]
+	}
+
+	rest = strings.TrimSuffix(rest, "/")
+
+	userinfo, endpoint, ok := splitUserinfo(rest)
+	if !ok {
+		return nil, parseError(ErrorMalformed, "invalid wireguard link format")
+	}
+
+	privateKey, err := url.PathUnescape(userinfo)
+	if err != nil || privateKey == "" {
+		return nil, parseError(ErrorMalformed, "invalid wireguard private key")
+	}
+
+	server, port, err := splitEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := &Proxy{
+		Name:       remark,
+		Type:       ProxyTypeWireGuard,
+		Server:     server,
+		Port:       port,
+		PrivateKey: privateKey,
+		UDP:        true,
+	}
+
+	if query != "" {
+		params, _ := url.ParseQuery(query)
+
+		proxy.PublicKey = wireGuardKey(firstParam(params, "publickey", "public_key", "peer_public_key"))
+		proxy.PreSharedKey = wireGuardKey(firstParam(params, "presharedkey", "pre_shared_key", "psk"))
+
+		if address := firstParam(params, "address", "ip", "local_address"); address != "" {
+			setWireGuardAddresses(proxy, address)
+		}
+
+		if allowed := firstParam(params, "allowedips", "allowed_ips"); allowed != "" {
+			proxy.AllowedIPs = splitList(allowed)
+		}
+
+		if reserved := params.Get("reserved"); reserved != "" {
+			proxy.Reserved, err = parseReserved(wireGuardKey(reserved))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if mtu := params.Get("mtu"); mtu != "" {
+			proxy.MTU, _ = strconv.Atoi(mtu)
+		}
+	}
+
+	if proxy.PublicKey == "" {
+		return nil, parseError(ErrorMissingField, "missing wireguard public key")
+	}
+
+	if proxy.Name == "" {
+		proxy.Name = fmt.Sprintf("%s:%d", server, port)
+	}
+
+	return proxy, nil
+}
+
+func IsWireGuardConfig(content string) bool {
+	return strings.Contains(content, "[Interface]") && strings.Contains(content, "[Peer]")
+}
+
+func ParseWireGuardConfig(content string, name string) (*Proxy, error) {
+	proxy := &Proxy{
+		Name: name,
+		Type: ProxyTypeWireGuard,
+		UDP:  true,
+	}
+
+	var peers []WireGuardPeer
+	var peer *WireGuardPeer
+	section := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.Trim(line, "[]"))
+			if section == "peer" {
+				peers = append(peers, WireGuardPeer{})
+				peer = &peers[len(peers)-1]
+			}
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		value := strings.TrimSpace(kv[1])
+
+		switch section {
+		case "interface":
+			switch key {
+			case "privatekey":
+				proxy.PrivateKey = value
+			case "address":
+				setWireGuardAddresses(proxy, value)
+			case "mtu":
+				proxy.MTU, _ = strconv.Atoi(value)
+			}
+		case "peer":
+			switch key {
+			case "publickey":
+				peer.PublicKey = value
+			case "presharedkey":
+				peer.PreSharedKey = value
+			case "allowedips":
+				peer.AllowedIPs = splitList(value)
+			case "endpoint":
+				server, port, err := splitEndpoint(value)
+				if err != nil {
+					return nil, err
+				}
+				peer.Server = server
+				peer.Port = port
+			case "reserved":
+				reserved, err := parseReserved(value)
+				if err != nil {
+					return nil, err
+				}
+				peer.Reserved = reserved
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read wireguard config: %v", err)
+	}
+
+	if proxy.PrivateKey == "" {
+		return nil, parseError(ErrorMissingField, "missing wireguard private key")
+	}
+
+	var valid []WireGuardPeer
+	for _, p := range peers {
+		if p.Server == "" || p.PublicKey == "" {
+			continue
+		}
+		valid = append(valid, p)
+	}
+
+	if len(valid) == 0 {
+		return nil, fmt.Errorf("wireguard config has no peer with endpoint and public key")
+	}
+
+	first := valid[0]
+	proxy.Server = first.Server
+	proxy.Port = first.Port
+	proxy.PublicKey = first.PublicKey
+	proxy.PreSharedKey = first.PreSharedKey
+	proxy.AllowedIPs = first.AllowedIPs
+	proxy.Reserved = first.Reserved
+
+	if len(valid) > 1 {
+		proxy.Peers = valid
+	}
+
+	if proxy.Name == "" {
+		proxy.Name = fmt.Sprintf("%s:%d", proxy.Server, proxy.Port)
+	}
+
+	return proxy, nil
+}
+
+// Base64 keys carry '+' which query decoding turns into spaces.
+func wireGuardKey(value string) string {
+	return strings.ReplaceAll(value, " ", "+")
+}
+
+func setWireGuardAddresses(proxy *Proxy, value string) {
+	for _, addr := range splitList(value) {
+		host := addr
+		if idx := strings.Index(host, "/"); idx != -1 {
+			host = host[:idx]
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil {
+			continue
+		}
+
+		if ip.To4() != nil {
+			if proxy.IP == "" {
+				proxy.IP = host
+			}
+		} else if proxy.IPv6 == "" {
+			proxy.IPv6 = host
+		}
+	}
+}
+
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func parseReserved(value string) ([]int, error) {
+	value = strings.Trim(strings.TrimSpace(value), "[]")
+
+	if strings.Contains(value, ",") {
+		var reserved []int
+		for _, item := range splitList(value) {
+			n, err := strconv.Atoi(item)
+			if err != nil || n < 0 || n > 255 {
+				return nil, parseError(ErrorMalformed, "invalid wireguard reserved value: %s", item)
+			}
+			reserved = append(reserved, n)
+		}
+		return reserved, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, parseError(ErrorMalformed, "invalid wireguard reserved value: %s", value)
+	}
+
+	reserved := make([]int, len(decoded))
+	for i, b := range decoded {
+		reserved[i] = int(b)
+	}
+	return reserved, nil
+}
@@ -0,0 +1,363 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+var xraySkipProtocols = map[string]bool{
+	"freedom": true, "blackhole": true, "dns": true, "loopback": true,
+}
+
+func ToXrayJSON(proxies []*Proxy) ([]byte, []error) {
+	config := outboundsConfig{Outbounds: []map[string]interface{}{}}
+	var errors []error
+
+	for _, proxy := range proxies {
+		outbound, err := proxy.ToXray()
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		config.Outbounds = append(config.Outbounds, outbound)
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, append(errors, fmt.Errorf("failed to marshal xray outbounds: %v", err))
+	}
+	return data, errors
+}
+
+func FromXray(m map[string]interface{}) (*Proxy, error) {
+	protocol := jsonString(m, "protocol")
+	if xraySkipProtocols[protocol] {
+		return nil, nil
+	}
+
+	settings := jsonMap(m, "settings")
+	if settings == nil {
+		return nil, fmt.Errorf("outbound %q has no settings", jsonString(m, "tag"))
+	}
+
+	// Full configs nest the endpoint in vnext/servers; the simplified
+	// format puts address and credentials directly into settings.
+	server := settings
+	if list := jsonList(settings, "vnext"); len(list) > 0 {
+		server = list[0]
+	} else if list := jsonList(settings, "servers"); len(list) > 0 {
+		server = list[0]
+	}
+
+	user := server
+	if users := jsonList(server, "users"); len(users) > 0 {
+		user = users[0]
+	}
+
+	proxy := &Proxy{
+		Name:   jsonString(m, "tag"),
+		Server: jsonString(server, "address"),
+		Port:   jsonInt(server, "port"),
+		UDP:    true,
+	}
+
+	switch protocol {
+	case "vmess":
+		proxy.Type = ProxyTypeVMess
+		proxy.UUID = jsonString(user, "id")
+		proxy.AlterId = jsonInt(user, "alterId")
+		proxy.Cipher = jsonString(user, "security")
+		if proxy.Cipher == "" {
+			proxy.Cipher = "auto"
+		}
+	case "vless":
+		proxy.Type = ProxyTypeVLess
+		proxy.UUID = jsonString(user, "id")
+		proxy.Flow = jsonString(user, "flow")
+	case "trojan":
+		proxy.Type = ProxyTypeTrojan
+		proxy.Password = jsonString(server, "password")
+	case "shadowsocks":
+		proxy.Type = ProxyTypeSS
+		proxy.Cipher = jsonString(server, "method")
+		proxy.Password = jsonString(server, "password")
+	case "socks":
+		proxy.Type = ProxyTypeSocks5
+		proxy.Username = jsonString(user, "user")
+		proxy.Password = jsonString(user, "pass")
+	case "http":
+		proxy.Type = ProxyTypeHTTP
+		proxy.UDP = false
+		proxy.Username = jsonString(user, "user")
+		proxy.Password = jsonString(user, "pass")
+	default:
+		return nil, fmt.Errorf("outbound %q has unsupported xray protocol %q", proxy.Name, protocol)
+	}
+
+	if stream := jsonMap(m, "streamSettings"); stream != nil {
+		readXrayStream(proxy, stream)
+	}
+
+	if proxy.Server == "" {
+		return nil, fmt.Errorf("outbound %q has no server", proxy.Name)
+	}
+
+	if proxy.Name == "" {
+		proxy.Name = fmt.Sprintf("%s:%d", proxy.Server, proxy.Port)
+	}
+
+	return proxy, nil
+}
+
+func readXrayStream(proxy *Proxy, stream map[string]interface{}) {
+	switch jsonString(stream, "security") {
+	case "tls":
+		proxy.TLS = true
+		if tls := jsonMap(stream, "tlsSettings"); tls != nil {
+			proxy.SNI = jsonString(tls, "serverName")
+			proxy.SkipCertVerify = jsonBool(tls, "allowInsecure")
+			proxy.ALPN = jsonStrings(tls, "alpn")
+			proxy.ClientFingerprint = jsonString(tls, "fingerprint")
+		}
+	case "reality":
+		proxy.TLS = true
+		if reality := jsonMap(stream, "realitySettings"); reality != nil {
+			proxy.SNI = jsonString(reality, "serverName")
+			proxy.ClientFingerprint = jsonString(reality, "fingerprint")
+			proxy.RealityOpts = &RealityOptions{
+				PublicKey: jsonString(reality, "publicKey"),
+				ShortID:   jsonString(reality, "shortId"),
+				SpiderX:   jsonString(reality, "spiderX"),
+			}
+		}
+	}
+
+	network := jsonString(stream, "network")
+	if network == "" || network == "raw" {
+		network = "tcp"
+	}
+	proxy.Network = network
+
+	var settings map[string]interface{}
+	switch network {
+	case "ws":
+		settings = jsonMap(stream, "wsSettings")
+		proxy.WSPath = jsonString(settings, "path")
+	case "grpc":
+		settings = jsonMap(stream, "grpcSettings")
+		proxy.GRPCServiceName = jsonString(settings, "serviceName")
+	case "httpupgrade":
+		settings = jsonMap(stream, "httpupgradeSettings")
+		proxy.HTTPUpgradePath = jsonString(settings, "path")
+	case "xhttp":
+		settings = jsonMap(stream, "xhttpSettings")
+		proxy.XHTTPPath = jsonString(settings, "path")
+		proxy.XHTTPMode = jsonString(settings, "mode")
+		proxy.XHTTPExtra = jsonMap(settings, "extra")
+	case "http", "h2":
+		settings = jsonMap(stream, "httpSettings")
+		proxy.Network = "h2"
+		proxy.H2Opts = &H2Options{
+			Host: jsonStrings(settings, "host"),
+			Path: jsonString(settings, "path"),
+		}
+		return
+	case "tcp":
+		header := jsonMap(jsonMap(stream, "tcpSettings"), "header")
+		if jsonString(header, "type") != "http" {
+			return
+		}
+		request := jsonMap(header, "request")
+		proxy.Network = "http"
+		proxy.HTTPOpts = &HTTPOptions{
+			Method: jsonString(request, "method"),
+			Path:   jsonStrings(request, "path"),
+		}
+		if headers := jsonMap(request, "headers"); headers != nil {
+			proxy.HTTPOpts.Headers = make(map[string][]string, len(headers))
+			for key := range headers {
+				proxy.HTTPOpts.Headers[key] = jsonStrings(headers, key)
+			}
+		}
+		return
+	case "splithttp":
+		settings = jsonMap(stream, "splithttpSettings")
+		proxy.SplitHTTPPath = jsonString(settings, "path")
+	}
+
+	if settings == nil {
+		return
+	}
+
+	host := jsonString(settings, "host")
+	if headers := jsonMap(settings, "headers"); headers != nil && host == "" {
+		host = jsonString(headers, "Host")
+	}
+	if host != "" {
+		proxy.WSHeaders = map[string]string{"Host": host}
+	}
+}
+
+func (p *Proxy) ToXray() (map[string]interface{}, error) {
+	m := map[string]interface{}{"tag": p.Name}
+
+	endpoint := map[string]interface{}{
+		"address": p.Server,
+		"port":    p.Port,
+	}
+
+	switch p.Type {
+	case ProxyTypeVMess:
+		m["protocol"] = "vmess"
+		endpoint["users"] = []interface{}{map[string]interface{}{
+			"id":       p.UUID,
+			"alterId":  p.AlterId,
+			"security": p.Cipher,
+		}}
+		m["settings"] = map[string]interface{}{"vnext": []interface{}{endpoint}}
+	case ProxyTypeVLess:
+		m["protocol"] = "vless"
+		user := map[string]interface{}{"id": p.UUID, "encryption": "none"}
+		if p.Flow != "" {
+			user["flow"] = p.Flow
+		}
+		endpoint["users"] = []interface{}{user}
+		m["settings"] = map[string]interface{}{"vnext": []interface{}{endpoint}}
+	case ProxyTypeTrojan:
+		m["protocol"] = "trojan"
+		endpoint["password"] = p.Password
+		m["settings"] = map[string]interface{}{"servers": []interface{}{endpoint}}
+	case ProxyTypeSS:
+		if p.Plugin != "" {
+			return nil, fmt.Errorf("cannot convert proxy %q to xray: shadowsocks plugins are not supported", p.Name)
+		}
+		m["protocol"] = "shadowsocks"
+		endpoint["method"] = p.Cipher
+		endpoint["password"] = p.Password
+		m["settings"] = map[string]interface{}{"servers": []interface{}{endpoint}}
+	case ProxyTypeSocks5, ProxyTypeHTTP:
+		m["protocol"] = "socks"
+		if p.Type == ProxyTypeHTTP {
+			m["protocol"] = "http"
+		}
+		if p.Username != "" {
+			endpoint["users"] = []interface{}{map[string]interface{}{"user": p.Username, "pass": p.Password}}
+		}
+		m["settings"] = map[string]interface{}{"servers": []interface{}{endpoint}}
+	default:
+		return nil, fmt.Errorf("cannot convert proxy %q to xray: unsupported type %q", p.Name, p.Type)
+	}
+
+	network := p.Network
+	switch network {
+	case "":
+		network = "tcp"
+	case "websocket":
+		network = "ws"
+	}
+	stream := map[string]interface{}{"network": network}
+
+	switch {
+	case p.RealityOpts != nil:
+		stream["security"] = "reality"
+		reality := map[string]interface{}{
+			"serverName": p.SNI,
+			"publicKey":  p.RealityOpts.PublicKey,
+			"shortId":    p.RealityOpts.ShortID,
+		}
+		if p.RealityOpts.SpiderX != "" {
+			reality["spiderX"] = p.RealityOpts.SpiderX
+		}
+		if p.ClientFingerprint != "" {
+			reality["fingerprint"] = p.ClientFingerprint
+		}
+		stream["realitySettings"] = reality
+	case p.TLS:
+		stream["security"] = "tls"
+		tls := map[string]interface{}{}
+		if p.SNI != "" {
+			tls["serverName"] = p.SNI
+		}
+		if p.SkipCertVerify {
+			tls["allowInsecure"] = true
+		}
+		if len(p.ALPN) > 0 {
+			tls["alpn"] = p.ALPN
+		}
+		if p.ClientFingerprint != "" {
+			tls["fingerprint"] = p.ClientFingerprint
+		}
+		stream["tlsSettings"] = tls
+	}
+
+	host, path := p.transportHostPath()
+	switch network {
+	case "ws":
+		ws := map[string]interface{}{"path": path}
+		if host != "" {
+			ws["headers"] = map[string]interface{}{"Host": host}
+		}
+		stream["wsSettings"] = ws
+	case "grpc":
+		stream["grpcSettings"] = map[string]interface{}{"serviceName": path}
+	case "httpupgrade", "xhttp", "splithttp":
+		settings := map[string]interface{}{"path": path}
+		if host != "" {
+			settings["host"] = host
+		}
+		if network == "xhttp" {
+			if p.XHTTPMode != "" {
+				settings["mode"] = p.XHTTPMode
+			}
+			if len(p.XHTTPExtra) > 0 {
+				settings["extra"] = p.XHTTPExtra
+			}
+		}
+		stream[network+"Settings"] = settings
+	case "h2":
+		stream["network"] = "http"
+		settings := map[string]interface{}{"path": path}
+		if p.H2Opts != nil && len(p.H2Opts.Host) > 0 {
+			settings["host"] = p.H2Opts.Host
+		}
+		stream["httpSettings"] = settings
+	case "http":
+		stream["network"] = "tcp"
+		request := map[string]interface{}{}
+		if p.HTTPOpts != nil {
+			if p.HTTPOpts.Method != "" {
+				request["method"] = p.HTTPOpts.Method
+			}
+			if len(p.HTTPOpts.Path) > 0 {
+				request["path"] = p.HTTPOpts.Path
+			}
+			if len(p.HTTPOpts.Headers) > 0 {
+				request["headers"] = p.HTTPOpts.Headers
+			}
+		}
+		stream["tcpSettings"] = map[string]interface{}{
+			"header": map[string]interface{}{"type": "http", "request": request},
+		}
+	case "tcp":
+	default:
+		return nil, fmt.Errorf("cannot convert proxy %q to xray: unsupported transport %q", p.Name, network)
+	}
+
+	m["streamSettings"] = stream
+	return m, nil
+}
+
+func jsonList(m map[string]interface{}, key string) []map[string]interface{} {
+	items, ok := m[key].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var result []map[string]interface{}
+	for _, item := range items {
+		if v, ok := item.(map[string]interface{}); ok {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -34,10 +34,11 @@ func NewAppHandler(cfg *config.Config, mihomoService *service.MihomoService, con
 // @Success 200 {object} map[string]interface{}
 // @Router /app/config [get]
 func (h *AppHandler) GetConfig(c *gin.Context) {
+	cfg := h.config.Snapshot()
 	c.JSON(http.StatusOK, gin.H{
-		"mihomo": h.mihomoService.GetAppConfig(),
+		"mihomo": cfg.Mihomo,
 		"logging": gin.H{
-			"level": h.config.Logging.Level,
+			"level": cfg.Logging.Level,
 		},
 	})
 }
@@ -264,6 +265,7 @@ func (h *AppHandler) GetGeoIPv6(c *gin.Context) {
 // @Param config body map[string]interface{} true "Configuration to update"
 // @Success 200 {object} map[string]interface{} "Success message"
 // @Failure 400 {object} map[string]interface{} "Bad request"
+// @Failure 409 {object} map[string]interface{} "Another operation is in progress"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /app/config [put]
 func (h *AppHandler) UpdateConfig(c *gin.Context) {
@@ -282,40 +284,33 @@ func (h *AppHandler) UpdateConfig(c *gin.Context) {
 		return
 	}
 
-	needsRestart := false
-
-	if req.Mihomo != nil {
-		h.config.Mihomo.CorePath = req.Mihomo.CorePath
-		h.config.Mihomo.ConfigPath = req.Mihomo.ConfigPath
-		h.config.Mihomo.WorkingDir = req.Mihomo.WorkingDir
-		h.config.Mihomo.AutoRestart = req.Mihomo.AutoRestart
-		h.config.Mihomo.LogFile = req.Mihomo.LogFile
-		h.config.Mihomo.APIURL = req.Mihomo.APIURL
-		h.config.Mihomo.APISecret = req.Mihomo.APISecret
-		h.config.Mihomo.Routing = req.Mihomo.Routing
-		needsRestart = req.Mihomo.AutoRestart && h.mihomoService.GetStatus() == "running"
-	}
-
-	if req.Logging != nil && req.Logging.Level != "" {
-		h.config.Logging.Level = req.Logging.Level
-	}
+	restarted, err := h.mihomoService.ChangeConfig(func(cfg *config.Config) bool {
+		if req.Logging != nil && req.Logging.Level != "" {
+			cfg.Logging.Level = req.Logging.Level
+		}
 
-	if err := h.config.Save(h.configPath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		if req.Mihomo == nil {
+			return false
+		}
+		cfg.Mihomo.CorePath = req.Mihomo.CorePath
+		cfg.Mihomo.ConfigPath = req.Mihomo.ConfigPath
+		cfg.Mihomo.WorkingDir = req.Mihomo.WorkingDir
+		cfg.Mihomo.AutoRestart = req.Mihomo.AutoRestart
+		cfg.Mihomo.LogFile = req.Mihomo.LogFile
+		cfg.Mihomo.APIURL = req.Mihomo.APIURL
+		cfg.Mihomo.APISecret = req.Mihomo.APISecret
+		cfg.Mihomo.Routing = req.Mihomo.Routing
+		return req.Mihomo.AutoRestart
+	})
+	if err != nil {
+		c.JSON(operationStatus(err), gin.H{
 			"success": false,
-			"error":   "failed to save config: " + err.Error(),
+			"error":   err.Error(),
 		})
 		return
 	}
 
-	if needsRestart {
-		if err := h.mihomoService.Restart(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   "config updated but failed to restart mihomo: " + err.Error(),
-			})
-			return
-		}
+	if restarted {
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
 			"message": "Configuration updated and mihomo restarted successfully",
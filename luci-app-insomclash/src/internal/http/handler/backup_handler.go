@@ -35,7 +35,7 @@ func NewBackupHandler(cfg *config.Config) *BackupHandler {
 // @Router /backup/create [post]
 func (h *BackupHandler) CreateBackup(c *gin.Context) {
 
-	workingDir := h.config.Mihomo.WorkingDir
+	workingDir := h.config.Snapshot().Mihomo.WorkingDir
 	timestamp := time.Now().Format("20060102-150405")
 	backupFilename := fmt.Sprintf("fusiontunx-backup-%s.tar.gz", timestamp)
 
@@ -146,7 +146,7 @@ func (h *BackupHandler) RestoreBackup(c *gin.Context) {
 	defer gzipReader.Close()
 
 	tarReader := tar.NewReader(gzipReader)
-	workingDir := h.config.Mihomo.WorkingDir
+	workingDir := h.config.Snapshot().Mihomo.WorkingDir
 
 	for {
 		header, err := tarReader.Next()
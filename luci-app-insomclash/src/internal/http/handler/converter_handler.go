@@ -1,36 +1,148 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"fusiontunx/internal/converter"
+	"fusiontunx/internal/service"
+	"fusiontunx/pkg/config"
 
 	"github.com/gin-gonic/gin"
 )
 
-type ConverterHandler struct{}
+type ConverterHandler struct {
+	appConfig           *config.Config
+	mihomoService       *service.MihomoService
+	subscriptionService *service.SubscriptionService
+}
 
-func NewConverterHandler() *ConverterHandler {
-	return &ConverterHandler{}
+func NewConverterHandler(appConfig *config.Config, mihomoService *service.MihomoService, subscriptionService *service.SubscriptionService) *ConverterHandler {
+	return &ConverterHandler{
+		appConfig:           appConfig,
+		mihomoService:       mihomoService,
+		subscriptionService: subscriptionService,
+	}
 }
 
 type ParseRequest struct {
-	URL     string `json:"url" example:"https://example.com/sub or vmess://..."`
-	Content string `json:"content,omitempty" example:"base64 encoded proxy list"`
+	URL       string `json:"url" example:"https://example.com/sub or vmess://..."`
+	Content   string `json:"content,omitempty" example:"base64 encoded proxy list"`
+	UserAgent string `json:"user_agent,omitempty" example:"clash.meta"`
+	ViaMihomo bool   `json:"via_mihomo,omitempty"`
+
+	Process *converter.ProcessOptions `json:"process,omitempty"`
 }
 
-type ParseResponse struct {
-	Success bool               `json:"success"`
+type ProviderRequest struct {
+	ParseRequest
+	Proxies     []*converter.Proxy          `json:"proxies,omitempty"`
+	Info        *converter.SubscriptionInfo `json:"info,omitempty"`
+	Filename    string                      `json:"filename,omitempty" example:"airport.yaml"`
+	SkipInvalid bool                        `json:"skip_invalid,omitempty"`
+}
+
+type ProviderResponse struct {
+	Success    bool                        `json:"success"`
+	Content    string                      `json:"content,omitempty"`
+	Count      int                         `json:"count"`
+	Info       *converter.SubscriptionInfo `json:"info,omitempty"`
+	Validation *converter.ValidationReport `json:"validation,omitempty"`
+	Error      string                      `json:"error,omitempty"`
+}
+
+type LinksRequest struct {
+	Proxies  []*converter.Proxy `json:"proxies,omitempty"`
+	Filename string             `json:"filename,omitempty" example:"provider.yaml"`
+}
+
+type LinksResponse struct {
+	Success      bool     `json:"success"`
+	Links        []string `json:"links,omitempty"`
+	Subscription string   `json:"subscription,omitempty"`
+	Count        int      `json:"count"`
+	Errors       []string `json:"errors,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+type ConvertRequest struct {
+	ParseRequest
 	Proxies []*converter.Proxy `json:"proxies,omitempty"`
-	Count   int                `json:"count"`
-	Error   string             `json:"error,omitempty"`
+	Target  string             `json:"target" example:"sing-box"`
+}
+
+type ConvertResponse struct {
+	Success bool     `json:"success"`
+	Target  string   `json:"target,omitempty"`
+	Content string   `json:"content,omitempty"`
+	Count   int      `json:"count"`
+	Errors  []string `json:"errors,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+type GenerateRequest struct {
+	converter.GenerateOptions
+	Subscriptions   []string       `json:"subscriptions,omitempty"`
+	Sources         []ParseRequest `json:"sources,omitempty"`
+	Template        string         `json:"template,omitempty" example:"config.yaml"`
+	TemplateContent string         `json:"template_content,omitempty"`
+	Filename        string         `json:"filename" binding:"required" example:"generated.yaml"`
+	Overwrite       bool           `json:"overwrite,omitempty"`
+	Activate        bool           `json:"activate,omitempty"`
+}
+
+type GenerateResponse struct {
+	Success   bool   `json:"success"`
+	Filename  string `json:"filename,omitempty"`
+	Content   string `json:"content,omitempty"`
+	Count     int    `json:"count"`
+	Active    bool   `json:"active"`
+	Restarted bool   `json:"restarted"`
+	Error     string `json:"error,omitempty"`
+}
+
+type ParseResponse struct {
+	Success    bool                        `json:"success"`
+	Format     string                      `json:"format,omitempty" example:"base64"`
+	Info       *converter.SubscriptionInfo `json:"info,omitempty"`
+	Proxies    []*converter.Proxy          `json:"proxies,omitempty"`
+	Count      int                         `json:"count"`
+	Report     *converter.ParseReport      `json:"report,omitempty"`
+	Validation *converter.ValidationReport `json:"validation,omitempty"`
+	Error      string                      `json:"error,omitempty"`
+}
+
+type QRCodeRequest struct {
+	Content string `json:"content" binding:"required" example:"vless://... or http://192.168.1.1:8080/sub"`
+	Size    int    `json:"size,omitempty" example:"320"`
+}
+
+type QRDecodeResponse struct {
+	Success    bool                        `json:"success"`
+	Texts      []string                    `json:"texts,omitempty"`
+	URLs       []string                    `json:"urls,omitempty"`
+	Proxies    []*converter.Proxy          `json:"proxies,omitempty"`
+	Count      int                         `json:"count"`
+	Report     *converter.ParseReport      `json:"report,omitempty"`
+	Validation *converter.ValidationReport `json:"validation,omitempty"`
+	Error      string                      `json:"error,omitempty"`
 }
 
+// maxQRUploadSize limits uploaded QR code images.
+const maxQRUploadSize = 5 << 20
+
 // ParseProxies godoc
 // @Summary Parse proxy links or subscription
-// @Description Auto-detects and parses: subscription URLs (http/https), single proxy links (vmess/vless/trojan/ss), or base64 content
-// @Description - For subscription URL: {"url": "https://example.com/sub"}
+// @Description Auto-detects and parses: subscription URLs (http/https), single proxy links (vmess/vless/trojan/ss/ssr/hysteria2/tuic/wireguard/socks5/http), base64 content, wg-quick .conf content, mihomo YAML, sing-box/Xray JSON, SIP008 JSON, or Surge/Quantumult X proxy lines
+// @Description The detected format is returned in "format"
+// @Description Optional "process" filters, renames, de-duplicates and country-tags the result; names are always made unique
+// @Description "validation" lists proxies with errors (mihomo would reject them) or warnings
+// @Description "report" lists every link or proxy line with its redacted text, type and, on failure, the error category, plus counts per protocol before processing; it is also returned when nothing could be parsed
+// @Description - For subscription URL: {"url": "https://example.com/sub"}; "user_agent" and "via_mihomo" (fetch through the mihomo mixed-port) are optional, other limits come from the app config's fetch section
 // @Description - For single link: {"url": "vmess://..."}
 // @Description - For base64 content: {"content": "base64..."}
 // @Tags Converter
@@ -39,6 +151,7 @@ type ParseResponse struct {
 // @Param request body ParseRequest true "Parse request with either url or content"
 // @Success 200 {object} ParseResponse
 // @Failure 400 {object} ParseResponse
+// @Failure 403 {object} ParseResponse "Subscription address blocked by fetch.block_private"
 // @Failure 500 {object} ParseResponse
 // @Router /converter/parse [post]
 
@@ -52,49 +165,643 @@ func (h *ConverterHandler) ParseProxies(c *gin.Context) {
 		return
 	}
 
-	var proxies []*converter.Proxy
-	var err error
+	sub, status, err := h.resolveProxies(&req)
+	if err != nil {
+		resp := ParseResponse{
+			Success: false,
+			Error:   err.Error(),
+		}
+		if sub != nil {
+			resp.Format = string(sub.Format)
+			resp.Report = sub.Report
+		}
+		c.JSON(status, resp)
+		return
+	}
 
-	if req.Content != "" && req.Content != "string" {
-		proxies, err = converter.ParseSubscription(req.Content)
-	} else if req.URL != "" {
-		if strings.HasPrefix(req.URL, "http://") || strings.HasPrefix(req.URL, "https://") {
-			proxies, err = converter.FetchSubscription(req.URL)
-		} else if strings.HasPrefix(req.URL, "vmess://") ||
-			strings.HasPrefix(req.URL, "vless://") ||
-			strings.HasPrefix(req.URL, "trojan://") ||
-			strings.HasPrefix(req.URL, "ss://") {
-			var proxy *converter.Proxy
-			proxy, err = converter.ParseLink(req.URL)
-			if err == nil {
-				proxies = []*converter.Proxy{proxy}
+	c.JSON(http.StatusOK, ParseResponse{
+		Success:    true,
+		Format:     string(sub.Format),
+		Info:       sub.Info,
+		Proxies:    sub.Proxies,
+		Count:      len(sub.Proxies),
+		Report:     sub.Report,
+		Validation: converter.ValidateProxies(sub.Proxies),
+	})
+}
+
+// ExportProvider godoc
+// @Summary Export proxies as a mihomo proxy provider
+// @Description Parses a subscription, link or content (or takes already parsed proxies) and renders them as mihomo proxies: YAML
+// @Description The returned content can be saved as-is into proxy_providers/
+// @Description With "filename" the provider is also written to proxy_providers/ and its subscription info stored next to it
+// @Description Proxies are validated against what mihomo accepts; a file is not saved while any proxy has errors unless "skip_invalid" drops them
+// @Tags Converter
+// @Accept json
+// @Produce json
+// @Param request body ProviderRequest true "Either url, content or proxies"
+// @Success 200 {object} ProviderResponse
+// @Failure 400 {object} ProviderResponse
+// @Failure 500 {object} ProviderResponse
+// @Router /converter/provider [post]
+func (h *ConverterHandler) ExportProvider(c *gin.Context) {
+	var req ProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ProviderResponse{
+			Success: false,
+			Error:   "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	var filePath string
+	if req.Filename != "" {
+		if filepath.Ext(req.Filename) != ".yaml" && filepath.Ext(req.Filename) != ".yml" {
+			c.JSON(http.StatusBadRequest, ProviderResponse{
+				Success: false,
+				Error:   "only yaml files are allowed",
+			})
+			return
+		}
+
+		providerDir := filepath.Join(h.appConfig.Snapshot().Mihomo.WorkingDir, "proxy_providers")
+		filePath = filepath.Join(providerDir, req.Filename)
+		if !isPathSafe(filePath, providerDir) {
+			c.JSON(http.StatusBadRequest, ProviderResponse{
+				Success: false,
+				Error:   "invalid filename",
+			})
+			return
+		}
+	}
+
+	proxies := req.Proxies
+	info := req.Info
+	if len(proxies) == 0 {
+		sub, status, err := h.resolveProxies(&req.ParseRequest)
+		if err != nil {
+			c.JSON(status, ProviderResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		proxies = sub.Proxies
+		info = sub.Info
+	}
+
+	// mihomo rejects a whole provider over one invalid proxy, so never save
+	// one; skip_invalid drops the offending proxies instead.
+	validation := converter.ValidateProxies(proxies)
+	if validation.Errors > 0 {
+		if req.SkipInvalid {
+			proxies, validation = converter.DropInvalid(proxies)
+		} else if filePath != "" {
+			c.JSON(http.StatusBadRequest, ProviderResponse{
+				Success:    false,
+				Validation: validation,
+				Error:      validation.Err().Error(),
+			})
+			return
+		}
+	}
+
+	content, err := converter.ToMihomoYAML(proxies)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ProviderResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if filePath != "" {
+		if err := os.WriteFile(filePath, content, 0644); err != nil {
+			c.JSON(http.StatusInternalServerError, ProviderResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+
+		// Keep the metadata in step with the provider so a manual export
+		// never shows the quota of an earlier subscription.
+		if info != nil {
+			err = converter.SaveSubscriptionInfo(filePath, info)
+		} else if err = os.Remove(converter.SubscriptionInfoPath(filePath)); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ProviderResponse{
+				Success: false,
+				Error:   "provider saved but failed to update subscription info: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, ProviderResponse{
+		Success:    true,
+		Content:    string(content),
+		Count:      len(proxies),
+		Info:       info,
+		Validation: validation,
+	})
+}
+
+// EncodeLinks godoc
+// @Summary Encode proxies into share links
+// @Description Encodes proxies back into share links (vmess/vless/trojan/ss/ssr/hysteria2/tuic/wireguard/socks5/http)
+// @Description - For parsed proxies: {"proxies": [...]}
+// @Description - For a proxy provider file: {"filename": "provider.yaml"}
+// @Tags Converter
+// @Accept json
+// @Produce json
+// @Param request body LinksRequest true "Either proxies or a proxy_providers filename"
+// @Success 200 {object} LinksResponse
+// @Failure 400 {object} LinksResponse
+// @Failure 404 {object} LinksResponse
+// @Failure 500 {object} LinksResponse
+// @Router /converter/links [post]
+func (h *ConverterHandler) EncodeLinks(c *gin.Context) {
+	var req LinksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, LinksResponse{
+			Success: false,
+			Error:   "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	proxies := req.Proxies
+	if len(proxies) == 0 {
+		if req.Filename == "" {
+			c.JSON(http.StatusBadRequest, LinksResponse{
+				Success: false,
+				Error:   "either 'proxies' or 'filename' is required",
+			})
+			return
+		}
+
+		if filepath.Ext(req.Filename) != ".yaml" && filepath.Ext(req.Filename) != ".yml" {
+			c.JSON(http.StatusBadRequest, LinksResponse{
+				Success: false,
+				Error:   "only yaml files are allowed",
+			})
+			return
+		}
+
+		providerDir := filepath.Join(h.appConfig.Snapshot().Mihomo.WorkingDir, "proxy_providers")
+		filePath := filepath.Join(providerDir, req.Filename)
+		if !isPathSafe(filePath, providerDir) {
+			c.JSON(http.StatusBadRequest, LinksResponse{
+				Success: false,
+				Error:   "invalid filename",
+			})
+			return
+		}
+
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if os.IsNotExist(err) {
+				status = http.StatusNotFound
 			}
-		} else {
-			c.JSON(http.StatusBadRequest, ParseResponse{
+			c.JSON(status, LinksResponse{
 				Success: false,
-				Error:   "invalid URL: must be http(s):// subscription or vmess/vless/trojan/ss link",
+				Error:   err.Error(),
 			})
 			return
 		}
-	} else {
-		c.JSON(http.StatusBadRequest, ParseResponse{
+
+		proxies, err = converter.ParseMihomoProxies(data)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, LinksResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	links, errs := converter.EncodeLinks(proxies)
+
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+
+	c.JSON(http.StatusOK, LinksResponse{
+		Success:      true,
+		Links:        links,
+		Subscription: converter.EncodeSubscription(links),
+		Count:        len(links),
+		Errors:       messages,
+	})
+}
+
+// ConvertProxies godoc
+// @Summary Convert proxies to another client format
+// @Description Parses a subscription, link or content (or takes already parsed proxies) and renders them for the requested target
+// @Description - target "mihomo": proxies: YAML for proxy_providers/
+// @Description - target "sing-box": JSON with an outbounds array
+// @Description - target "xray": JSON with an outbounds array
+// @Description Proxies the target cannot represent are skipped and reported in errors
+// @Tags Converter
+// @Accept json
+// @Produce json
+// @Param request body ConvertRequest true "Target plus either url, content or proxies"
+// @Success 200 {object} ConvertResponse
+// @Failure 400 {object} ConvertResponse
+// @Failure 500 {object} ConvertResponse
+// @Router /converter/convert [post]
+func (h *ConverterHandler) ConvertProxies(c *gin.Context) {
+	var req ConvertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ConvertResponse{
+			Success: false,
+			Error:   "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if req.Target != "mihomo" && req.Target != "sing-box" && req.Target != "xray" {
+		c.JSON(http.StatusBadRequest, ConvertResponse{
+			Success: false,
+			Error:   "invalid target: must be mihomo, sing-box or xray",
+		})
+		return
+	}
+
+	proxies := req.Proxies
+	if len(proxies) == 0 {
+		sub, status, err := h.resolveProxies(&req.ParseRequest)
+		if err != nil {
+			c.JSON(status, ConvertResponse{
+				Success: false,
+				Target:  req.Target,
+				Error:   err.Error(),
+			})
+			return
+		}
+		proxies = sub.Proxies
+	}
+
+	var content []byte
+	var errs []error
+	count := len(proxies)
+
+	switch req.Target {
+	case "mihomo":
+		var err error
+		content, err = converter.ToMihomoYAML(proxies)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	case "sing-box":
+		content, errs = converter.ToSingBoxJSON(proxies)
+		count -= len(errs)
+	case "xray":
+		content, errs = converter.ToXrayJSON(proxies)
+		count -= len(errs)
+	}
+
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+
+	if content == nil {
+		c.JSON(http.StatusInternalServerError, ConvertResponse{
+			Success: false,
+			Target:  req.Target,
+			Errors:  messages,
+			Error:   "failed to render " + req.Target + " config",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConvertResponse{
+		Success: true,
+		Target:  req.Target,
+		Content: string(content),
+		Count:   count,
+		Errors:  messages,
+	})
+}
+
+// GenerateConfig godoc
+// @Summary Generate a mihomo config
+// @Description Builds a complete mihomo config from a template and one or more proxy sources and saves it into configs/
+// @Description - Sources: stored subscription IDs and proxy_providers files become file providers; "sources" (url/content) and "proxies" are embedded
+// @Description - Template: "template" (a file in configs/), "template_content", or the built-in template based on the bundled config
+// @Description - Groups: a main select group, optional Auto/Fallback/Load Balance groups and region url-test groups
+// @Description - Template groups with other names and template rules are kept; one referring to a proxy, group or provider missing from the result is rejected
+// @Description With "activate" the generated file becomes the active config
+// @Description Embedded proxies with validation errors are rejected
+// @Tags Converter
+// @Accept json
+// @Produce json
+// @Param request body GenerateRequest true "Generate request"
+// @Success 200 {object} GenerateResponse
+// @Failure 400 {object} GenerateResponse
+// @Failure 404 {object} GenerateResponse
+// @Failure 500 {object} GenerateResponse
+// @Router /converter/generate [post]
+func (h *ConverterHandler) GenerateConfig(c *gin.Context) {
+	var req GenerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, GenerateResponse{
+			Success: false,
+			Error:   "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	mihomo := h.appConfig.Snapshot().Mihomo
+	configDir := filepath.Join(mihomo.WorkingDir, "configs")
+	providerDir := filepath.Join(mihomo.WorkingDir, "proxy_providers")
+
+	fail := func(status int, message string) {
+		c.JSON(status, GenerateResponse{
+			Success: false,
+			Error:   message,
+		})
+	}
+
+	if filepath.Ext(req.Filename) != ".yaml" && filepath.Ext(req.Filename) != ".yml" {
+		fail(http.StatusBadRequest, "only yaml files are allowed")
+		return
+	}
+
+	filePath := filepath.Join(configDir, req.Filename)
+	if !isPathSafe(filePath, configDir) {
+		fail(http.StatusBadRequest, "invalid filename")
+		return
+	}
+
+	if _, err := os.Stat(filePath); err == nil && !req.Overwrite {
+		fail(http.StatusBadRequest, "file already exists")
+		return
+	}
+
+	var template []byte
+	if req.TemplateContent != "" {
+		template = []byte(req.TemplateContent)
+	} else if req.Template != "" {
+		templatePath := filepath.Join(configDir, req.Template)
+		if !isPathSafe(templatePath, configDir) {
+			fail(http.StatusBadRequest, "invalid template")
+			return
+		}
+
+		data, err := os.ReadFile(templatePath)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if os.IsNotExist(err) {
+				status = http.StatusNotFound
+			}
+			fail(status, err.Error())
+			return
+		}
+		template = data
+	}
+
+	for _, id := range req.Subscriptions {
+		sub, err := h.subscriptionService.Get(id)
+		if err != nil {
+			fail(http.StatusNotFound, "subscription "+id+": "+err.Error())
+			return
+		}
+		req.Providers = append(req.Providers, sub.Provider)
+	}
+
+	for _, provider := range req.Providers {
+		providerPath := filepath.Join(providerDir, provider)
+		if filepath.Base(provider) != provider || !isPathSafe(providerPath, providerDir) {
+			fail(http.StatusBadRequest, "invalid provider: "+provider)
+			return
+		}
+		if _, err := os.Stat(providerPath); err != nil {
+			fail(http.StatusNotFound, "provider "+provider+" does not exist")
+			return
+		}
+	}
+
+	for i := range req.Sources {
+		sub, status, err := h.resolveProxies(&req.Sources[i])
+		if err != nil {
+			fail(status, err.Error())
+			return
+		}
+		req.Proxies = append(req.Proxies, sub.Proxies...)
+	}
+
+	if err := converter.ValidateProxies(req.Proxies).Err(); err != nil {
+		fail(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	content, err := converter.GenerateConfig(template, req.GenerateOptions)
+	if err != nil {
+		fail(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		fail(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := GenerateResponse{
+		Success:  true,
+		Filename: req.Filename,
+		Content:  string(content),
+		Count:    len(req.Proxies),
+		Active:   filePath == mihomo.ConfigPath,
+	}
+
+	if req.Activate && !resp.Active {
+		resp.Restarted, err = h.mihomoService.SetActiveConfig(filePath)
+		if err != nil {
+			fail(http.StatusInternalServerError, "config generated but activation failed: "+err.Error())
+			return
+		}
+		resp.Active = true
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// EncodeQRCode godoc
+// @Summary Render a QR code
+// @Description Renders a proxy share link or an http(s) subscription URL as a PNG QR code for scanning with a phone
+// @Description "size" is the image width and height in pixels (64-2048, default 320)
+// @Tags Converter
+// @Accept json
+// @Produce png
+// @Param request body QRCodeRequest true "Link or URL to encode"
+// @Success 200 {file} binary "PNG image"
+// @Failure 400 {object} map[string]string "Error message"
+// @Router /converter/qrcode [post]
+func (h *ConverterHandler) EncodeQRCode(c *gin.Context) {
+	var req QRCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	content := strings.TrimSpace(req.Content)
+	if !converter.IsProxyLink(content) && !strings.HasPrefix(content, "http://") && !strings.HasPrefix(content, "https://") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content must be a supported proxy link or an http(s) URL"})
+		return
+	}
+
+	data, err := converter.EncodeQRCode(content, req.Size)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The code carries credentials, so keep it out of caches.
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/png", data)
+}
+
+// DecodeQRCode godoc
+// @Summary Decode QR codes from an image
+// @Description Reads every QR code in an uploaded PNG, JPEG or GIF image (up to 5 MB)
+// @Description Proxy share links found in the codes are parsed like /converter/parse does; http(s) subscription URLs are returned in "urls" so they can be passed to /converter/parse
+// @Description "texts" holds the raw text of every code
+// @Tags Converter
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Image with one or more QR codes"
+// @Success 200 {object} QRDecodeResponse
+// @Failure 400 {object} QRDecodeResponse
+// @Failure 422 {object} QRDecodeResponse "No QR code or no usable link found"
+// @Router /converter/qrcode/decode [post]
+func (h *ConverterHandler) DecodeQRCode(c *gin.Context) {
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, QRDecodeResponse{
+			Success: false,
+			Error:   "no file uploaded: " + err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxQRUploadSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, QRDecodeResponse{
+			Success: false,
+			Error:   "failed to read file: " + err.Error(),
+		})
+		return
+	}
+	if len(data) > maxQRUploadSize {
+		c.JSON(http.StatusBadRequest, QRDecodeResponse{
 			Success: false,
-			Error:   "either 'url' or 'content' is required",
+			Error:   "image is larger than 5 MB",
 		})
 		return
 	}
 
+	texts, err := converter.DecodeQRCode(data)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ParseResponse{
+		c.JSON(http.StatusUnprocessableEntity, QRDecodeResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, ParseResponse{
+	// A code may hold several links, one per line.
+	var links, urls []string
+	for _, text := range texts {
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimSpace(line)
+			switch {
+			case converter.IsProxyLink(line):
+				links = append(links, line)
+			case strings.HasPrefix(line, "http://"), strings.HasPrefix(line, "https://"):
+				urls = append(urls, line)
+			}
+		}
+	}
+
+	resp := QRDecodeResponse{
 		Success: true,
-		Proxies: proxies,
-		Count:   len(proxies),
-	})
+		Texts:   texts,
+		URLs:    urls,
+	}
+
+	if len(links) > 0 {
+		proxies, report := converter.ParseLinksReport(links)
+		proxies, err = converter.ProcessProxies(proxies, nil)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, QRDecodeResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		resp.Proxies = proxies
+		resp.Count = len(proxies)
+		resp.Report = report
+		resp.Validation = converter.ValidateProxies(proxies)
+	}
+
+	if resp.Count == 0 && len(urls) == 0 {
+		resp.Success = false
+		resp.Error = "no supported proxy link or subscription url found in qr code"
+		c.JSON(http.StatusUnprocessableEntity, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// resolveProxies parses the request's url or content. When parsing fails the
+// returned subscription may still hold the parse report.
+func (h *ConverterHandler) resolveProxies(req *ParseRequest) (*converter.Subscription, int, error) {
+	var sub *converter.Subscription
+	var err error
+
+	if req.Content != "" && req.Content != "string" {
+		sub, err = converter.ParseSubscriptionContent(req.Content)
+	} else if req.URL != "" {
+		if converter.IsProxyLink(req.URL) {
+			sub = &converter.Subscription{Format: converter.FormatLinks}
+			sub.Proxies, sub.Report = converter.ParseLinksReport([]string{req.URL})
+			if len(sub.Proxies) == 0 {
+				err = errors.New(sub.Report.Results[0].Error)
+			}
+		} else if strings.HasPrefix(req.URL, "http://") || strings.HasPrefix(req.URL, "https://") {
+			opts, optsErr := h.subscriptionService.FetchOptions(req.UserAgent, req.ViaMihomo)
+			if optsErr != nil {
+				return nil, http.StatusBadRequest, optsErr
+			}
+			sub, err = converter.FetchSubscription(req.URL, opts)
+			if errors.Is(err, converter.ErrBlockedAddress) {
+				return nil, http.StatusForbidden, err
+			}
+		} else {
+			return nil, http.StatusBadRequest, errors.New("invalid URL: must be http(s):// subscription or a supported proxy link")
+		}
+	} else {
+		return nil, http.StatusBadRequest, errors.New("either 'url' or 'content' is required")
+	}
+
+	if err != nil {
+		return sub, http.StatusInternalServerError, err
+	}
+
+	sub.Proxies, err = converter.ProcessProxies(sub.Proxies, req.Process)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	return sub, http.StatusOK, nil
 }
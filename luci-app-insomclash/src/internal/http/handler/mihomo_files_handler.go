@@ -7,8 +7,10 @@ import (
 	"path/filepath"
 	"strings"
 
+	"fusiontunx/internal/converter"
 	"fusiontunx/internal/service"
 	"fusiontunx/pkg/config"
+	"fusiontunx/pkg/logger"
 
 	"github.com/gin-gonic/gin"
 )
@@ -49,7 +51,7 @@ func (h *MihomoFilesHandler) GetFiles(c *gin.Context) {
 		return
 	}
 
-	dirPath := filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName)
+	dirPath := filepath.Join(h.appConfig.Snapshot().Mihomo.WorkingDir, dirName)
 	files, err := os.ReadDir(dirPath)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -58,7 +60,7 @@ func (h *MihomoFilesHandler) GetFiles(c *gin.Context) {
 
 	var fileNames []string
 	for _, file := range files {
-		if !file.IsDir() {
+		if !file.IsDir() && !strings.HasSuffix(file.Name(), ".info.json") {
 			fileNames = append(fileNames, file.Name())
 		}
 	}
@@ -91,9 +93,10 @@ func (h *MihomoFilesHandler) GetFileContent(c *gin.Context) {
 		return
 	}
 
-	filePath := filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName, filename)
+	mihomo := h.appConfig.Snapshot().Mihomo
+	filePath := filepath.Join(mihomo.WorkingDir, dirName, filename)
 
-	if !isPathSafe(filePath, filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName)) {
+	if !isPathSafe(filePath, filepath.Join(mihomo.WorkingDir, dirName)) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filename"})
 		return
 	}
@@ -142,9 +145,10 @@ func (h *MihomoFilesHandler) CreateFile(c *gin.Context) {
 		return
 	}
 
-	filePath := filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName, req.Filename)
+	mihomo := h.appConfig.Snapshot().Mihomo
+	filePath := filepath.Join(mihomo.WorkingDir, dirName, req.Filename)
 
-	if !isPathSafe(filePath, filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName)) {
+	if !isPathSafe(filePath, filepath.Join(mihomo.WorkingDir, dirName)) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filename"})
 		return
 	}
@@ -174,6 +178,7 @@ func (h *MihomoFilesHandler) CreateFile(c *gin.Context) {
 // @Param request body map[string]string true "New content"
 // @Success 200 {object} map[string]string "Success message"
 // @Failure 400 {object} map[string]string "Error message"
+// @Failure 409 {object} map[string]string "Another operation is in progress"
 // @Failure 500 {object} map[string]string "Error message"
 // @Router /mihomo/{dir}/{filename} [put]
 func (h *MihomoFilesHandler) UpdateFile(c *gin.Context) {
@@ -199,9 +204,10 @@ func (h *MihomoFilesHandler) UpdateFile(c *gin.Context) {
 		return
 	}
 
-	filePath := filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName, filename)
+	mihomo := h.appConfig.Snapshot().Mihomo
+	filePath := filepath.Join(mihomo.WorkingDir, dirName, filename)
 
-	if !isPathSafe(filePath, filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName)) {
+	if !isPathSafe(filePath, filepath.Join(mihomo.WorkingDir, dirName)) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filename"})
 		return
 	}
@@ -217,10 +223,10 @@ func (h *MihomoFilesHandler) UpdateFile(c *gin.Context) {
 		return
 	}
 
-	if h.appConfig.Mihomo.AutoRestart && h.mihomoService.GetStatus() == "running" &&
-		(dirName == "configs" && filePath == h.appConfig.Mihomo.ConfigPath) {
+	if mihomo.AutoRestart && h.mihomoService.GetStatus() == "running" &&
+		(dirName == "configs" && filePath == mihomo.ConfigPath) {
 		if err := h.mihomoService.Restart(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "file updated but failed to restart mihomo: " + err.Error()})
+			c.JSON(operationStatus(err), gin.H{"error": "file updated but failed to restart mihomo: " + err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "File updated and mihomo restarted successfully"})
@@ -255,9 +261,10 @@ func (h *MihomoFilesHandler) DeleteFile(c *gin.Context) {
 		return
 	}
 
-	filePath := filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName, filename)
+	mihomo := h.appConfig.Snapshot().Mihomo
+	filePath := filepath.Join(mihomo.WorkingDir, dirName, filename)
 
-	if !isPathSafe(filePath, filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName)) {
+	if !isPathSafe(filePath, filepath.Join(mihomo.WorkingDir, dirName)) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filename"})
 		return
 	}
@@ -267,7 +274,7 @@ func (h *MihomoFilesHandler) DeleteFile(c *gin.Context) {
 		return
 	}
 
-	if dirName == "configs" && filePath == h.appConfig.Mihomo.ConfigPath {
+	if dirName == "configs" && filePath == mihomo.ConfigPath {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot delete active config file"})
 		return
 	}
@@ -278,6 +285,10 @@ func (h *MihomoFilesHandler) DeleteFile(c *gin.Context) {
 		return
 	}
 
+	if err := os.Remove(converter.SubscriptionInfoPath(filePath)); err != nil && !os.IsNotExist(err) {
+		logger.Warnf("Failed to remove subscription info for %s: %v", filePath, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "File deleted successfully"})
 }
 
@@ -292,6 +303,7 @@ func (h *MihomoFilesHandler) DeleteFile(c *gin.Context) {
 // @Param request body map[string]string true "New filename"
 // @Success 200 {object} map[string]string "Success message"
 // @Failure 400 {object} map[string]string "Error message"
+// @Failure 409 {object} map[string]string "Another operation is in progress"
 // @Failure 500 {object} map[string]string "Error message"
 // @Router /mihomo/{dir}/{filename}/rename [put]
 func (h *MihomoFilesHandler) RenameFile(c *gin.Context) {
@@ -322,11 +334,12 @@ func (h *MihomoFilesHandler) RenameFile(c *gin.Context) {
 		return
 	}
 
-	oldPath := filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName, filename)
-	newPath := filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName, req.NewFilename)
+	mihomo := h.appConfig.Snapshot().Mihomo
+	oldPath := filepath.Join(mihomo.WorkingDir, dirName, filename)
+	newPath := filepath.Join(mihomo.WorkingDir, dirName, req.NewFilename)
 
-	if !isPathSafe(oldPath, filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName)) ||
-		!isPathSafe(newPath, filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName)) {
+	if !isPathSafe(oldPath, filepath.Join(mihomo.WorkingDir, dirName)) ||
+		!isPathSafe(newPath, filepath.Join(mihomo.WorkingDir, dirName)) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filename"})
 		return
 	}
@@ -347,10 +360,17 @@ func (h *MihomoFilesHandler) RenameFile(c *gin.Context) {
 		return
 	}
 
-	if dirName == "configs" && oldPath == h.appConfig.Mihomo.ConfigPath {
-		h.appConfig.Mihomo.ConfigPath = newPath
-		if err := h.appConfig.Save(h.configPath); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "file renamed but failed to update app config: " + err.Error()})
+	if err := os.Rename(converter.SubscriptionInfoPath(oldPath), converter.SubscriptionInfoPath(newPath)); err != nil && !os.IsNotExist(err) {
+		logger.Warnf("Failed to move subscription info for %s: %v", oldPath, err)
+	}
+
+	if dirName == "configs" && oldPath == mihomo.ConfigPath {
+		_, err := h.mihomoService.ChangeConfig(func(cfg *config.Config) bool {
+			cfg.Mihomo.ConfigPath = newPath
+			return false
+		})
+		if err != nil {
+			c.JSON(operationStatus(err), gin.H{"error": "file renamed but failed to update app config: " + err.Error()})
 			return
 		}
 	}
@@ -358,6 +378,53 @@ func (h *MihomoFilesHandler) RenameFile(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "File renamed successfully"})
 }
 
+// GetSubscriptionInfo godoc
+// @Summary Get subscription info of a provider file
+// @Description Get the traffic quota, expiry and update interval stored next to a proxy provider when it was saved from a subscription
+// @Tags Mihomo files
+// @Produce json
+// @Param dir path string true "Directory name (proxy_providers)"
+// @Param filename path string true "Filename of the provider"
+// @Success 200 {object} converter.SubscriptionInfo
+// @Failure 400 {object} map[string]string "Error message"
+// @Failure 404 {object} map[string]string "Error message"
+// @Failure 500 {object} map[string]string "Error message"
+// @Router /mihomo/{dir}/{filename}/info [get]
+func (h *MihomoFilesHandler) GetSubscriptionInfo(c *gin.Context) {
+	dirName := c.Param("dir")
+	filename := c.Param("filename")
+
+	if dirName != "proxy_providers" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid directory"})
+		return
+	}
+
+	if filepath.Ext(filename) != ".yaml" && filepath.Ext(filename) != ".yml" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only yaml files are allowed"})
+		return
+	}
+
+	mihomo := h.appConfig.Snapshot().Mihomo
+	filePath := filepath.Join(mihomo.WorkingDir, dirName, filename)
+
+	if !isPathSafe(filePath, filepath.Join(mihomo.WorkingDir, dirName)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filename"})
+		return
+	}
+
+	info, err := converter.LoadSubscriptionInfo(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no subscription info for this file"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
 // DownloadFile godoc
 // @Summary Download a file
 // @Description Download a specific file
@@ -383,9 +450,10 @@ func (h *MihomoFilesHandler) DownloadFile(c *gin.Context) {
 		return
 	}
 
-	filePath := filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName, filename)
+	mihomo := h.appConfig.Snapshot().Mihomo
+	filePath := filepath.Join(mihomo.WorkingDir, dirName, filename)
 
-	if !isPathSafe(filePath, filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName)) {
+	if !isPathSafe(filePath, filepath.Join(mihomo.WorkingDir, dirName)) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filename"})
 		return
 	}
@@ -437,9 +505,10 @@ func (h *MihomoFilesHandler) UploadFile(c *gin.Context) {
 		return
 	}
 
-	filePath := filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName, filename)
+	mihomo := h.appConfig.Snapshot().Mihomo
+	filePath := filepath.Join(mihomo.WorkingDir, dirName, filename)
 
-	if !isPathSafe(filePath, filepath.Join(h.appConfig.Mihomo.WorkingDir, dirName)) {
+	if !isPathSafe(filePath, filepath.Join(mihomo.WorkingDir, dirName)) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filename"})
 		return
 	}
@@ -473,7 +542,7 @@ func (h *MihomoFilesHandler) UploadFile(c *gin.Context) {
 // @Failure 500 {object} map[string]interface{} "Error message"
 // @Router /mihomo/active-config [get]
 func (h *MihomoFilesHandler) GetActiveConfigPath(c *gin.Context) {
-	relativePath := filepath.Base(h.appConfig.Mihomo.ConfigPath)
+	relativePath := filepath.Base(h.appConfig.Snapshot().Mihomo.ConfigPath)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
@@ -492,6 +561,7 @@ func (h *MihomoFilesHandler) GetActiveConfigPath(c *gin.Context) {
 // @Success 200 {object} map[string]interface{} "Success message"
 // @Failure 400 {object} map[string]interface{} "Error message"
 // @Failure 404 {object} map[string]interface{} "File not found"
+// @Failure 409 {object} map[string]interface{} "Another operation is in progress"
 // @Failure 500 {object} map[string]interface{} "Error message"
 // @Router /mihomo/active-config [put]
 func (h *MihomoFilesHandler) SetActiveConfigPath(c *gin.Context) {
@@ -509,9 +579,10 @@ func (h *MihomoFilesHandler) SetActiveConfigPath(c *gin.Context) {
 		return
 	}
 
-	newPath := filepath.Join(h.appConfig.Mihomo.WorkingDir, "configs", req.Filename)
+	mihomo := h.appConfig.Snapshot().Mihomo
+	newPath := filepath.Join(mihomo.WorkingDir, "configs", req.Filename)
 
-	if !isPathSafe(newPath, filepath.Join(h.appConfig.Mihomo.WorkingDir, "configs")) {
+	if !isPathSafe(newPath, filepath.Join(mihomo.WorkingDir, "configs")) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filename"})
 		return
 	}
@@ -521,17 +592,13 @@ func (h *MihomoFilesHandler) SetActiveConfigPath(c *gin.Context) {
 		return
 	}
 
-	h.appConfig.Mihomo.ConfigPath = newPath
-	if err := h.appConfig.Save(h.configPath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update app config: " + err.Error()})
+	restarted, err := h.mihomoService.SetActiveConfig(newPath)
+	if err != nil {
+		c.JSON(operationStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	if h.appConfig.Mihomo.AutoRestart && h.mihomoService.GetStatus() == "running" {
-		if err := h.mihomoService.Restart(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "config updated but failed to restart mihomo: " + err.Error()})
-			return
-		}
+	if restarted {
 		c.JSON(http.StatusOK, gin.H{"message": "Active config updated and mihomo restarted successfully"})
 		return
 	}
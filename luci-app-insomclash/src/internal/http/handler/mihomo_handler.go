@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -31,15 +32,25 @@ func (h *MihomoHandler) createRequest(method, url string, body io.Reader) (*http
 	if err != nil {
 		return nil, err
 	}
-	if h.appConfig.Mihomo.APISecret != "" {
-		req.Header.Set("Authorization", "Bearer "+h.appConfig.Mihomo.APISecret)
+	mihomo := h.appConfig.Snapshot().Mihomo
+	if mihomo.APISecret != "" {
+		req.Header.Set("Authorization", "Bearer "+mihomo.APISecret)
 	}
 	return req, nil
 }
 
+// operationStatus maps the error of a lifecycle or config change to its HTTP
+// status, 409 when another change is still in progress.
+func operationStatus(err error) int {
+	if errors.Is(err, service.ErrOperationInProgress) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
 // GetStatus godoc
 // @Summary Get mihomo status
-// @Description Get current status of mihomo service
+// @Description Get current status of mihomo service, its lifecycle state and its crash restart state
 // @Tags Mihomo
 // @Accept json
 // @Produce json
@@ -50,7 +61,9 @@ func (h *MihomoHandler) GetStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
-			"running": status == "running",
+			"running":    status == "running",
+			"lifecycle":  h.mihomoService.Lifecycle(),
+			"supervisor": h.mihomoService.Supervisor(),
 		},
 	})
 }
@@ -62,12 +75,13 @@ func (h *MihomoHandler) GetStatus(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Success 200 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Another operation is in progress"
 // @Failure 500 {object} map[string]interface{}
 // @Router /mihomo/start [post]
 func (h *MihomoHandler) Start(c *gin.Context) {
 	err := h.mihomoService.Start()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(operationStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Mihomo service started"})
@@ -75,20 +89,24 @@ func (h *MihomoHandler) Start(c *gin.Context) {
 
 // Stop godoc
 // @Summary Stop mihomo service
-// @Description Stop the mihomo service
+// @Description Stop the mihomo service with SIGTERM, falling back to SIGKILL after mihomo.stop_timeout. "stop" reports which was used.
 // @Tags Mihomo
 // @Accept json
 // @Produce json
 // @Success 200 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Another operation is in progress"
 // @Failure 500 {object} map[string]interface{}
 // @Router /mihomo/stop [post]
 func (h *MihomoHandler) Stop(c *gin.Context) {
 	err := h.mihomoService.Stop(true)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(operationStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Mihomo service stopped"})
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Mihomo service stopped",
+		"stop":    h.mihomoService.Lifecycle().LastStop,
+	})
 }
 
 // Restart godoc
@@ -98,12 +116,13 @@ func (h *MihomoHandler) Stop(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Success 200 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Another operation is in progress"
 // @Failure 500 {object} map[string]interface{}
 // @Router /mihomo/restart [post]
 func (h *MihomoHandler) Restart(c *gin.Context) {
 	err := h.mihomoService.Restart()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(operationStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Mihomo service restarted"})
@@ -133,7 +152,7 @@ func (h *MihomoHandler) ProxyToMihomoAPI(c *gin.Context) {
 	}
 
 	path := c.Param("path")
-	url := fmt.Sprintf("%s%s", h.appConfig.Mihomo.APIURL, path)
+	url := fmt.Sprintf("%s%s", h.appConfig.Snapshot().Mihomo.APIURL, path)
 
 	req, err := h.createRequest("GET", url, nil)
 	if err != nil {
@@ -176,7 +195,7 @@ func (h *MihomoHandler) ProxyToMihomoAPI(c *gin.Context) {
 // @Failure 500 {object} map[string]interface{} "Error message"
 // @Router /mihomo/core-version [get]
 func (h *MihomoHandler) GetCoreVersion(c *gin.Context) {
-	corePath := h.appConfig.Mihomo.CorePath
+	corePath := h.appConfig.Snapshot().Mihomo.CorePath
 	if corePath == "" {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -213,7 +232,8 @@ func (h *MihomoHandler) GetCoreVersion(c *gin.Context) {
 // @Failure 500 {object} map[string]interface{} "Error message"
 // @Router /mihomo/dashboard-info [get]
 func (h *MihomoHandler) GetDashboardInfo(c *gin.Context) {
-	uiPath := h.appConfig.Mihomo.WorkingDir + "/ui"
+	mihomo := h.appConfig.Snapshot().Mihomo
+	uiPath := mihomo.WorkingDir + "/ui"
 	var availableDashboards []string
 
 	entries, err := os.ReadDir(uiPath)
@@ -226,8 +246,8 @@ func (h *MihomoHandler) GetDashboardInfo(c *gin.Context) {
 	}
 
 	port := "9090"
-	if h.appConfig.Mihomo.APIURL != "" {
-		parts := strings.Split(h.appConfig.Mihomo.APIURL, ":")
+	if mihomo.APIURL != "" {
+		parts := strings.Split(mihomo.APIURL, ":")
 		if len(parts) >= 3 {
 			port = parts[2]
 		}
@@ -237,7 +257,7 @@ func (h *MihomoHandler) GetDashboardInfo(c *gin.Context) {
 		"success": true,
 		"data": gin.H{
 			"port":       port,
-			"secret":     h.appConfig.Mihomo.APISecret,
+			"secret":     mihomo.APISecret,
 			"dashboards": availableDashboards,
 		},
 	})
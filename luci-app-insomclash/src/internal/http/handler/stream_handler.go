@@ -47,7 +47,7 @@ func (h *StreamHandler) StreamMihomoLogs(c *gin.Context) {
 		return
 	}
 
-	logFile := h.config.Mihomo.LogFile
+	logFile := h.config.Snapshot().Mihomo.LogFile
 	h.streamLogFile(c, conn, logFile)
 }
 
@@ -58,12 +58,12 @@ func (h *StreamHandler) StreamAppLogs(c *gin.Context) {
 	}
 	defer conn.Close()
 
-	logFile := h.config.Logging.File
+	logFile := h.config.Snapshot().Logging.File
 	h.streamLogFile(c, conn, logFile)
 }
 
 func (h *StreamHandler) ClearMihomoLogs(c *gin.Context) {
-	logFile := h.config.Mihomo.LogFile
+	logFile := h.config.Snapshot().Mihomo.LogFile
 	if logFile == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "log file not configured"})
 		return
@@ -80,7 +80,7 @@ func (h *StreamHandler) ClearMihomoLogs(c *gin.Context) {
 }
 
 func (h *StreamHandler) ClearAppLogs(c *gin.Context) {
-	logFile := h.config.Logging.File
+	logFile := h.config.Snapshot().Logging.File
 	if logFile == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "log file not configured"})
 		return
@@ -248,14 +248,15 @@ func (h *StreamHandler) StreamConnections(c *gin.Context) {
 	for {
 		select {
 		case <-ticker.C:
-			url := h.config.Mihomo.APIURL + "/connections"
+			mihomo := h.config.Snapshot().Mihomo
+			url := mihomo.APIURL + "/connections"
 			req, err := http.NewRequest("GET", url, nil)
 			if err != nil {
 				continue
 			}
 
-			if h.config.Mihomo.APISecret != "" {
-				req.Header.Set("Authorization", "Bearer "+h.config.Mihomo.APISecret)
+			if mihomo.APISecret != "" {
+				req.Header.Set("Authorization", "Bearer "+mihomo.APISecret)
 			}
 
 			client := &http.Client{Timeout: 5 * time.Second}
@@ -280,8 +281,64 @@ func (h *StreamHandler) StreamConnections(c *gin.Context) {
 	}
 }
 
+// StreamEvents sends the current lifecycle as a "mihomo.lifecycle" message,
+// then a "mihomo.state" message for every state transition.
+func (h *StreamHandler) StreamEvents(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Subscribe before taking the snapshot so no transition falls between.
+	events, unsubscribe := h.mihomoService.Events().Subscribe()
+	defer unsubscribe()
+
+	snapshot := service.Event{
+		Type: "mihomo.lifecycle",
+		Time: time.Now(),
+		Data: h.mihomoService.Lifecycle(),
+	}
+	if err := conn.WriteJSON(snapshot); err != nil {
+		return
+	}
+
+	// The client never sends anything; reading notices when it goes away.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !strings.HasPrefix(event.Type, "mihomo.") {
+				continue
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+
+		case <-closed:
+			return
+
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
 func (h *StreamHandler) streamMihomoAPI(c *gin.Context, conn *websocket.Conn, endpoint string) {
-	url := h.config.Mihomo.APIURL + endpoint
+	mihomo := h.config.Snapshot().Mihomo
+	url := mihomo.APIURL + endpoint
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -289,8 +346,8 @@ func (h *StreamHandler) streamMihomoAPI(c *gin.Context, conn *websocket.Conn, en
 		return
 	}
 
-	if h.config.Mihomo.APISecret != "" {
-		req.Header.Set("Authorization", "Bearer "+h.config.Mihomo.APISecret)
+	if mihomo.APISecret != "" {
+		req.Header.Set("Authorization", "Bearer "+mihomo.APISecret)
 	}
 
 	client := &http.Client{}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"fusiontunx/internal/service"
+	"fusiontunx/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SubscriptionHandler struct {
+	subscriptionService *service.SubscriptionService
+}
+
+func NewSubscriptionHandler(subscriptionService *service.SubscriptionService) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		subscriptionService: subscriptionService,
+	}
+}
+
+type SubscriptionRequest struct {
+	Name      string `json:"name" binding:"required" example:"My airport"`
+	URL       string `json:"url" binding:"required" example:"https://example.com/sub"`
+	UserAgent string `json:"user_agent,omitempty" example:"clash.meta"`
+	Interval  int    `json:"interval" example:"720"`
+	Provider  string `json:"provider" binding:"required" example:"airport.yaml"`
+	Enabled   *bool  `json:"enabled,omitempty"`
+	ViaMihomo bool   `json:"via_mihomo,omitempty"`
+
+	Process *config.ProcessConfig `json:"process,omitempty"`
+}
+
+func (r *SubscriptionRequest) toConfig() config.SubscriptionConfig {
+	enabled := true
+	if r.Enabled != nil {
+		enabled = *r.Enabled
+	}
+
+	return config.SubscriptionConfig{
+		Name:      r.Name,
+		URL:       r.URL,
+		UserAgent: r.UserAgent,
+		Interval:  r.Interval,
+		Provider:  r.Provider,
+		Enabled:   enabled,
+		ViaMihomo: r.ViaMihomo,
+		Process:   r.Process,
+	}
+}
+
+// ListSubscriptions godoc
+// @Summary List subscriptions
+// @Description List stored subscriptions with their refresh status and quota info
+// @Tags Subscriptions
+// @Produce json
+// @Success 200 {array} service.Subscription
+// @Router /subscriptions [get]
+func (h *SubscriptionHandler) ListSubscriptions(c *gin.Context) {
+	c.JSON(http.StatusOK, h.subscriptionService.List())
+}
+
+// GetSubscription godoc
+// @Summary Get a subscription
+// @Tags Subscriptions
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} service.Subscription
+// @Failure 404 {object} map[string]string "Error message"
+// @Router /subscriptions/{id} [get]
+func (h *SubscriptionHandler) GetSubscription(c *gin.Context) {
+	sub, err := h.subscriptionService.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(subscriptionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sub)
+}
+
+// CreateSubscription godoc
+// @Summary Add a subscription
+// @Description Store a subscription that is refreshed every interval minutes into proxy_providers/<provider>
+// @Description An interval of 0 disables scheduled refresh
+// @Description With "via_mihomo" the subscription is fetched through the running mihomo's mixed-port
+// @Tags Subscriptions
+// @Accept json
+// @Produce json
+// @Param request body SubscriptionRequest true "Subscription"
+// @Success 201 {object} service.Subscription
+// @Failure 400 {object} map[string]string "Error message"
+// @Failure 500 {object} map[string]string "Error message"
+// @Router /subscriptions [post]
+func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
+	var req SubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub, err := h.subscriptionService.Add(req.toConfig())
+	if err != nil {
+		c.JSON(subscriptionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, sub)
+}
+
+// UpdateSubscription godoc
+// @Summary Update a subscription
+// @Tags Subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Param request body SubscriptionRequest true "Subscription"
+// @Success 200 {object} service.Subscription
+// @Failure 400 {object} map[string]string "Error message"
+// @Failure 404 {object} map[string]string "Error message"
+// @Failure 409 {object} map[string]string "Subscription is being refreshed"
+// @Failure 500 {object} map[string]string "Error message"
+// @Router /subscriptions/{id} [put]
+func (h *SubscriptionHandler) UpdateSubscription(c *gin.Context) {
+	var req SubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub, err := h.subscriptionService.Update(c.Param("id"), req.toConfig())
+	if err != nil {
+		c.JSON(subscriptionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sub)
+}
+
+// DeleteSubscription godoc
+// @Summary Delete a subscription
+// @Description Delete a subscription; the provider file is kept unless remove_provider=true
+// @Tags Subscriptions
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Param remove_provider query bool false "Also delete the provider file"
+// @Success 200 {object} map[string]string "Success message"
+// @Failure 404 {object} map[string]string "Error message"
+// @Failure 409 {object} map[string]string "Subscription is being refreshed"
+// @Failure 500 {object} map[string]string "Error message"
+// @Router /subscriptions/{id} [delete]
+func (h *SubscriptionHandler) DeleteSubscription(c *gin.Context) {
+	removeProvider := c.Query("remove_provider") == "true"
+
+	if err := h.subscriptionService.Delete(c.Param("id"), removeProvider); err != nil {
+		c.JSON(subscriptionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Subscription deleted successfully"})
+}
+
+// RefreshSubscription godoc
+// @Summary Refresh a subscription now
+// @Description Fetch the subscription, rewrite its provider file and reload the provider in mihomo
+// @Description On failure the previous provider file is kept and the error is returned with the current status
+// @Tags Subscriptions
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} service.Subscription
+// @Failure 404 {object} map[string]string "Error message"
+// @Failure 409 {object} map[string]string "Error message"
+// @Failure 502 {object} map[string]interface{} "Error message and subscription"
+// @Router /subscriptions/{id}/refresh [post]
+func (h *SubscriptionHandler) RefreshSubscription(c *gin.Context) {
+	sub, err := h.subscriptionService.Refresh(c.Param("id"))
+	if err != nil {
+		if sub == nil {
+			c.JSON(subscriptionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "subscription": sub})
+		return
+	}
+
+	c.JSON(http.StatusOK, sub)
+}
+
+func subscriptionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrSubscriptionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrSubscriptionRefreshing):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrInvalidSubscription):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
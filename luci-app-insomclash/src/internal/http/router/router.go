@@ -9,7 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func Setup(app *gin.Engine, mihomoService *service.MihomoService, nftablesService *service.NftablesService, cfg *config.Config, configPath string) {
+func Setup(app *gin.Engine, mihomoService *service.MihomoService, nftablesService *service.NftablesService, subscriptionService *service.SubscriptionService, cfg *config.Config, configPath string) {
 	app.Use(gin.Logger())
 	app.Use(gin.Recovery())
 	app.Use(middleware.CORS(&cfg.API.CORS))
@@ -19,8 +19,9 @@ func Setup(app *gin.Engine, mihomoService *service.MihomoService, nftablesServic
 	mihomoFilesHandler := handler.NewMihomoFilesHandler(mihomoService, cfg, configPath)
 	streamHandler := handler.NewStreamHandler(cfg, mihomoService)
 	backupHandler := handler.NewBackupHandler(cfg)
-	converterHandler := handler.NewConverterHandler()
+	converterHandler := handler.NewConverterHandler(cfg, mihomoService, subscriptionService)
 	dnsHandler := handler.NewDNSHandler()
+	subscriptionHandler := handler.NewSubscriptionHandler(subscriptionService)
 
 	api := app.Group("/api/v1")
 	{
@@ -33,6 +34,22 @@ func Setup(app *gin.Engine, mihomoService *service.MihomoService, nftablesServic
 		converterGroup := api.Group("/converter")
 		{
 			converterGroup.POST("/parse", converterHandler.ParseProxies)
+			converterGroup.POST("/provider", converterHandler.ExportProvider)
+			converterGroup.POST("/links", converterHandler.EncodeLinks)
+			converterGroup.POST("/convert", converterHandler.ConvertProxies)
+			converterGroup.POST("/generate", converterHandler.GenerateConfig)
+			converterGroup.POST("/qrcode", converterHandler.EncodeQRCode)
+			converterGroup.POST("/qrcode/decode", converterHandler.DecodeQRCode)
+		}
+
+		subscriptionGroup := api.Group("/subscriptions")
+		{
+			subscriptionGroup.GET("", subscriptionHandler.ListSubscriptions)
+			subscriptionGroup.POST("", subscriptionHandler.CreateSubscription)
+			subscriptionGroup.GET("/:id", subscriptionHandler.GetSubscription)
+			subscriptionGroup.PUT("/:id", subscriptionHandler.UpdateSubscription)
+			subscriptionGroup.DELETE("/:id", subscriptionHandler.DeleteSubscription)
+			subscriptionGroup.POST("/:id/refresh", subscriptionHandler.RefreshSubscription)
 		}
 
 		dnsGroup := api.Group("/dns")
@@ -51,6 +68,7 @@ func Setup(app *gin.Engine, mihomoService *service.MihomoService, nftablesServic
 			mihomoGroup.GET("/memory", streamHandler.StreamMemory)
 			mihomoGroup.GET("/traffic", streamHandler.StreamTraffic)
 			mihomoGroup.GET("/connections", streamHandler.StreamConnections)
+			mihomoGroup.GET("/events", streamHandler.StreamEvents)
 			mihomoGroup.GET("/core-version", mihomoHandler.GetCoreVersion)
 			mihomoGroup.GET("/dashboard-info", mihomoHandler.GetDashboardInfo)
 			mihomoGroup.GET("/api/*path", mihomoHandler.ProxyToMihomoAPI)
@@ -102,6 +120,10 @@ func Setup(app *gin.Engine, mihomoService *service.MihomoService, nftablesServic
 				c.Params = append(c.Params, gin.Param{Key: "dir", Value: "proxy_providers"})
 				mihomoFilesHandler.DownloadFile(c)
 			})
+			mihomoGroup.GET("/proxy-providers/:filename/info", func(c *gin.Context) {
+				c.Params = append(c.Params, gin.Param{Key: "dir", Value: "proxy_providers"})
+				mihomoFilesHandler.GetSubscriptionInfo(c)
+			})
 			mihomoGroup.POST("/proxy-providers", func(c *gin.Context) {
 				c.Params = append(c.Params, gin.Param{Key: "dir", Value: "proxy_providers"})
 				mihomoFilesHandler.CreateFile(c)
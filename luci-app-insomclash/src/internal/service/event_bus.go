@@ -0,0 +1,72 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"fusiontunx/pkg/logger"
+)
+
+// eventBufferSize is the number of events a subscriber may fall behind
+// before further events are dropped for it.
+const eventBufferSize = 64
+
+// Event is a message published on the EventBus. Type names the kind of
+// event, such as "mihomo.state", and Data holds its payload.
+type Event struct {
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// EventBus fans out internal events to any number of subscribers. Publishing
+// never blocks: a subscriber that does not keep up misses events.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving every event published from now on
+// and a function that ends the subscription and closes the channel.
+func (b *EventBus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (b *EventBus) Publish(eventType string, data interface{}) {
+	event := Event{
+		Type: eventType,
+		Time: time.Now(),
+		Data: data,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			logger.Debugf("Dropping %s event for slow subscriber", eventType)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"fusiontunx/pkg/logger"
+)
+
+// LifecycleState is the state of the mihomo core as seen by fusiontunx.
+type LifecycleState string
+
+const (
+	// StateStarting covers launching the core until it is ready and routing
+	// is set up.
+	StateStarting LifecycleState = "starting"
+	// StateReady means the core runs and routing, if enabled, is in place.
+	StateReady LifecycleState = "ready"
+	// StateDegraded means the core runs but routing could not be set up.
+	StateDegraded LifecycleState = "degraded"
+	StateStopping LifecycleState = "stopping"
+	StateStopped  LifecycleState = "stopped"
+	// StateCrashed means the core exited on its own. The supervisor may
+	// restart it from here.
+	StateCrashed LifecycleState = "crashed"
+)
+
+// EventMihomoState is published on every lifecycle transition with a
+// StateChange as data.
+const EventMihomoState = "mihomo.state"
+
+// lifecycleTransitions lists the states reachable from each state.
+var lifecycleTransitions = map[LifecycleState][]LifecycleState{
+	StateStopped:  {StateStarting},
+	StateStarting: {StateReady, StateDegraded, StateStopped, StateCrashed},
+	StateReady:    {StateStarting, StateStopping, StateCrashed},
+	StateDegraded: {StateStarting, StateStopping, StateCrashed},
+	StateStopping: {StateStopped, StateReady, StateDegraded},
+	StateCrashed:  {StateStarting, StateStopped},
+}
+
+// Lifecycle is a snapshot of the core lifecycle. Transitions holds the last
+// time each state was entered and LastStop how the core was last stopped.
+type Lifecycle struct {
+	State       LifecycleState               `json:"state"`
+	Since       time.Time                    `json:"since"`
+	PID         int                          `json:"pid,omitempty"`
+	LastError   string                       `json:"last_error,omitempty"`
+	LastErrorAt *time.Time                   `json:"last_error_at,omitempty"`
+	LastStop    *StopResult                  `json:"last_stop,omitempty"`
+	Transitions map[LifecycleState]time.Time `json:"transitions"`
+}
+
+// StateChange is the data of an EventMihomoState event.
+type StateChange struct {
+	From  LifecycleState `json:"from"`
+	To    LifecycleState `json:"to"`
+	PID   int            `json:"pid,omitempty"`
+	Error string         `json:"error,omitempty"`
+}
+
+// initLifecycle sets the initial state, adopting a core left running by a
+// previous fusiontunx instance.
+func (s *MihomoService) initLifecycle() {
+	now := time.Now()
+	s.state = StateStopped
+	if pid, ok := s.runningPID(); ok {
+		s.state = StateReady
+		s.statePID = pid
+	}
+	s.stateSince = now
+	s.transitions = map[LifecycleState]time.Time{s.state: now}
+}
+
+// setState moves the lifecycle to state and publishes the change. A non-nil
+// err becomes the last error. Transitions not in lifecycleTransitions are
+// refused.
+func (s *MihomoService) setState(state LifecycleState, pid int, err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setStateLocked(state, pid, err)
+}
+
+// setStateFrom is setState limited to the case where the current state is
+// from.
+func (s *MihomoService) setStateFrom(from, state LifecycleState, pid int, err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state != from {
+		return false
+	}
+	return s.setStateLocked(state, pid, err)
+}
+
+func (s *MihomoService) setStateLocked(state LifecycleState, pid int, err error) bool {
+	allowed := false
+	for _, next := range lifecycleTransitions[s.state] {
+		if next == state {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		logger.Warnf("Ignoring mihomo state change from %s to %s", s.state, state)
+		return false
+	}
+
+	now := time.Now()
+	change := StateChange{From: s.state, To: state, PID: pid}
+	if err != nil {
+		s.lastError = err.Error()
+		s.lastErrorAt = now
+		change.Error = s.lastError
+	}
+
+	s.state = state
+	s.statePID = pid
+	s.stateSince = now
+	s.transitions[state] = now
+
+	logger.Debugf("Mihomo state %s -> %s", change.From, change.To)
+	if s.events != nil {
+		s.events.Publish(EventMihomoState, change)
+	}
+	return true
+}
+
+// refreshState notices a core that is gone without the supervisor seeing it
+// exit, which happens for a core adopted from a previous fusiontunx instance.
+func (s *MihomoService) refreshState(running bool) {
+	if running {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if (s.state == StateReady || s.state == StateDegraded) && s.proc == nil {
+		s.setStateLocked(StateCrashed, 0, fmt.Errorf("mihomo process %d is gone", s.statePID))
+	}
+}
+
+// Lifecycle returns the current lifecycle state.
+func (s *MihomoService) Lifecycle() Lifecycle {
+	_, running := s.runningPID()
+	s.refreshState(running)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lifecycle := Lifecycle{
+		State:       s.state,
+		Since:       s.stateSince,
+		PID:         s.statePID,
+		LastError:   s.lastError,
+		LastStop:    s.lastStop,
+		Transitions: make(map[LifecycleState]time.Time, len(s.transitions)),
+	}
+	if !s.lastErrorAt.IsZero() {
+		at := s.lastErrorAt
+		lifecycle.LastErrorAt = &at
+	}
+	for state, at := range s.transitions {
+		lifecycle.Transitions[state] = at
+	}
+	return lifecycle
+}
+
+// Events returns the bus lifecycle events are published on.
+func (s *MihomoService) Events() *EventBus {
+	return s.events
+}
+
+// runningPID returns the pid of the running core from the pid file. A stale
+// pid file, including one whose pid has been reused by another program, is
+// removed.
+func (s *MihomoService) runningPID() (int, bool) {
+	pidFile := filepath.Join(s.mihomoConfig().WorkingDir, "mihomo.pid")
+	pidData, err := os.ReadFile(pidFile)
+	if err != nil {
+		return 0, false
+	}
+
+	var pid int
+	if _, err := fmt.Sscanf(string(pidData), "%d", &pid); err != nil || pid <= 0 {
+		os.Remove(pidFile)
+		return 0, false
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil || process.Signal(syscall.Signal(0)) != nil {
+		os.Remove(pidFile)
+		return 0, false
+	}
+
+	if !s.isMihomoProcess(pid) {
+		logger.Warnf("PID %d from %s is not mihomo, removing stale PID file", pid, pidFile)
+		os.Remove(pidFile)
+		return 0, false
+	}
+	return pid, true
+}
+
+// isMihomoProcess checks through /proc that pid runs the configured core
+// binary with our working directory. Without /proc it cannot tell and
+// trusts the pid.
+func (s *MihomoService) isMihomoProcess(pid int) bool {
+	if _, err := os.Stat("/proc/self"); err != nil {
+		return true
+	}
+
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil || len(cmdline) == 0 {
+		// Gone, or a zombie waiting to be reaped.
+		return false
+	}
+	args := strings.Split(string(bytes.TrimRight(cmdline, "\x00")), "\x00")
+
+	// argv[1] holds the path when the core is a script run by its
+	// interpreter.
+	cfg := s.mihomoConfig()
+	corePath := cfg.CorePath
+	binaryMatch := args[0] == corePath || len(args) > 1 && args[1] == corePath
+	if exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); err == nil {
+		// A core updated in place shows up as "<path> (deleted)".
+		exe = strings.TrimSuffix(exe, " (deleted)")
+		binaryMatch = binaryMatch || exe == resolvePath(corePath)
+	}
+	if !binaryMatch {
+		return false
+	}
+
+	workingDir := filepath.Clean(cfg.WorkingDir)
+	for i := 1; i+1 < len(args); i++ {
+		if args[i] == "-d" && filepath.Clean(args[i+1]) == workingDir {
+			return true
+		}
+	}
+	return false
+}
+
+func resolvePath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrOperationInProgress is returned when a lifecycle or config change is
+// requested while a different one is still running.
+var ErrOperationInProgress = errors.New("operation in progress")
+
+type operationMode int
+
+const (
+	// opCoalesce shares the result of a running operation with the same
+	// name and refuses any other.
+	opCoalesce operationMode = iota
+	// opReject refuses to run while any operation is running.
+	opReject
+	// opWait waits for running operations to finish, for internal callers
+	// that cannot retry.
+	opWait
+)
+
+// operation is a lifecycle or config change in progress. Only one runs at a
+// time, so the core is never spawned twice or killed by a stale pid and
+// routing is set up and torn down in order.
+type operation struct {
+	name string
+	done chan struct{}
+	err  error
+}
+
+// operate runs fn as the named operation.
+func (s *MihomoService) operate(name string, mode operationMode, fn func() error) error {
+	s.mu.Lock()
+	for s.operation != nil {
+		current := s.operation
+		s.mu.Unlock()
+
+		switch {
+		case mode == opCoalesce && current.name == name:
+			<-current.done
+			return current.err
+		case mode != opWait:
+			return fmt.Errorf("%w: %s", ErrOperationInProgress, current.name)
+		}
+
+		<-current.done
+		s.mu.Lock()
+	}
+
+	op := &operation{name: name, done: make(chan struct{})}
+	s.operation = op
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.operation = nil
+		s.mu.Unlock()
+		close(op.done)
+	}()
+
+	op.err = fn()
+	return op.err
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fusiontunx/pkg/config"
+)
+
+// fakeCore stands in for mihomo: it records each launch once it handles
+// SIGTERM, and takes a while to exit on it so operations stay in progress
+// long enough to race.
+const fakeCore = `#!/bin/sh
+trap 'sleep 0.5; exit 0' TERM
+echo $$ >> "$2/launches"
+while true; do sleep 0.1; done
+`
+
+func newTestMihomoService(t *testing.T) *MihomoService {
+	t.Helper()
+
+	dir := t.TempDir()
+	corePath := filepath.Join(dir, "mihomo")
+	if err := os.WriteFile(corePath, []byte(fakeCore), 0755); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("mixed-port: 7890\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Mihomo: config.MihomoConfig{
+			CorePath:    corePath,
+			ConfigPath:  configPath,
+			WorkingDir:  dir,
+			StopTimeout: 5,
+			Routing: config.RoutingConfig{
+				TCP: config.RoutingModeDisable,
+				UDP: config.RoutingModeDisable,
+			},
+		},
+	}
+
+	s := NewMihomoService(cfg, filepath.Join(dir, "fusiontunx.yaml"), nil, NewEventBus())
+	t.Cleanup(func() {
+		if s.GetStatus() == "running" {
+			s.StopAndWait(false)
+		}
+	})
+	return s
+}
+
+func launches(s *MihomoService) int {
+	data, _ := os.ReadFile(filepath.Join(s.mihomoConfig().WorkingDir, "launches"))
+	return len(strings.Fields(string(data)))
+}
+
+// waitLaunches waits until the fake core has been launched want times, the
+// last one being ready for SIGTERM.
+func waitLaunches(t *testing.T, s *MihomoService, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for launches(s) < want && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := launches(s); got != want {
+		t.Fatalf("core launched %d times, want %d", got, want)
+	}
+}
+
+func startCore(t *testing.T, s *MihomoService) {
+	t.Helper()
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	waitLaunches(t, s, 1)
+}
+
+// waitOperation waits until the named operation is running.
+func waitOperation(t *testing.T, s *MihomoService, name string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		running := s.operation != nil && s.operation.name == name
+		s.mu.Unlock()
+		if running {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("operation %q did not start", name)
+}
+
+func TestRestartCoalesces(t *testing.T) {
+	s := newTestMihomoService(t)
+	startCore(t, s)
+
+	first := make(chan error, 1)
+	go func() { first <- s.Restart() }()
+	waitOperation(t, s, "restart")
+
+	const callers = 8
+	errs := make(chan error, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.Restart()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	if err := <-first; err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	for err := range errs {
+		if err != nil {
+			t.Errorf("coalesced Restart() error = %v", err)
+		}
+	}
+
+	waitLaunches(t, s, 2)
+	if state := s.Lifecycle().State; state != StateReady {
+		t.Errorf("state = %s, want %s", state, StateReady)
+	}
+}
+
+func TestStartDuringStopIsRejected(t *testing.T) {
+	s := newTestMihomoService(t)
+	startCore(t, s)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- s.Stop(false) }()
+	waitOperation(t, s, "stop")
+
+	if err := s.Start(); !errors.Is(err, ErrOperationInProgress) {
+		t.Errorf("Start() during Stop error = %v, want %v", err, ErrOperationInProgress)
+	}
+
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	waitLaunches(t, s, 1)
+	if state := s.Lifecycle().State; state != StateStopped {
+		t.Errorf("state = %s, want %s", state, StateStopped)
+	}
+}
+
+func TestChangeConfigDuringRestartIsRejected(t *testing.T) {
+	s := newTestMihomoService(t)
+	startCore(t, s)
+
+	restarted := make(chan error, 1)
+	go func() { restarted <- s.Restart() }()
+	waitOperation(t, s, "restart")
+
+	called := false
+	_, err := s.ChangeConfig(func(cfg *config.Config) bool {
+		called = true
+		cfg.Mihomo.LogFile = "changed.log"
+		return true
+	})
+	if !errors.Is(err, ErrOperationInProgress) {
+		t.Errorf("ChangeConfig() during Restart error = %v, want %v", err, ErrOperationInProgress)
+	}
+	if called {
+		t.Error("ChangeConfig() ran its update during Restart")
+	}
+
+	if err := <-restarted; err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	if logFile := s.mihomoConfig().LogFile; logFile != "" {
+		t.Errorf("log file = %q, want it unchanged", logFile)
+	}
+}
+
+func TestRestartDuringChangeConfigIsRejected(t *testing.T) {
+	s := newTestMihomoService(t)
+
+	release := make(chan struct{})
+	changed := make(chan error, 1)
+	go func() {
+		_, err := s.ChangeConfig(func(cfg *config.Config) bool {
+			<-release
+			cfg.Mihomo.StopTimeout = 1
+			return false
+		})
+		changed <- err
+	}()
+	waitOperation(t, s, "update config")
+
+	if err := s.Restart(); !errors.Is(err, ErrOperationInProgress) {
+		t.Errorf("Restart() during ChangeConfig error = %v, want %v", err, ErrOperationInProgress)
+	}
+
+	close(release)
+	if err := <-changed; err != nil {
+		t.Fatalf("ChangeConfig() error = %v", err)
+	}
+	if timeout := s.mihomoConfig().StopTimeout; timeout != 1 {
+		t.Errorf("stop timeout = %d, want 1", timeout)
+	}
+}
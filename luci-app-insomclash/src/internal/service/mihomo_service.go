@@ -7,7 +7,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"syscall"
+	"sync"
 	"time"
 
 	"fusiontunx/pkg/config"
@@ -27,87 +27,103 @@ type MihomoServiceInterface interface {
 	GetLogs(lines int) ([]string, error)
 }
 
+// errRoutingFailed marks a start where the core came up but routing could
+// not be set up, leaving the service degraded.
+var errRoutingFailed = errors.New("routing setup failed")
+
 type MihomoService struct {
 	appConfig       *config.Config
 	configPath      string
 	nftablesService *NftablesService
+	events          *EventBus
+
+	mu           sync.Mutex
+	operation    *operation
+	state        LifecycleState
+	stateSince   time.Time
+	statePID     int
+	lastError    string
+	lastErrorAt  time.Time
+	transitions  map[LifecycleState]time.Time
+	proc         *mihomoProcess
+	restartTimer *time.Timer
+	nextRestart  time.Time
+	restarts     []time.Time
+	gaveUp       bool
+	lastExit     *ExitStatus
+	lastStop     *StopResult
 }
 
-func NewMihomoService(appConfig *config.Config, configPath string, nftablesService *NftablesService) *MihomoService {
-	return &MihomoService{
+func NewMihomoService(appConfig *config.Config, configPath string, nftablesService *NftablesService, events *EventBus) *MihomoService {
+	s := &MihomoService{
 		appConfig:       appConfig,
 		configPath:      configPath,
 		nftablesService: nftablesService,
+		events:          events,
 	}
+	s.initLifecycle()
+	return s
 }
 
+// GetStatus reports whether the core process is running, "running" or
+// "stopped". Lifecycle has the detailed state.
 func (s *MihomoService) GetStatus() string {
-	pidFile := filepath.Join(s.appConfig.Mihomo.WorkingDir, "mihomo.pid")
-	pidData, err := os.ReadFile(pidFile)
-	if err != nil {
-		return "stopped"
-	}
-
-	var pid int
-	_, err = fmt.Sscanf(string(pidData), "%d", &pid)
-	if err != nil {
-		return "stopped"
-	}
-
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		return "stopped"
-	}
-
-	err = process.Signal(syscall.Signal(0))
-	if err != nil {
-		os.Remove(pidFile)
+	_, running := s.runningPID()
+	s.refreshState(running)
+	if !running {
 		return "stopped"
 	}
-
 	return "running"
 }
 
 func (s *MihomoService) killExistingMihomo() error {
-	pidFile := filepath.Join(s.appConfig.Mihomo.WorkingDir, "mihomo.pid")
-	pidData, err := os.ReadFile(pidFile)
-	if err != nil {
-		logger.Debug("No existing mihomo PID file found")
+	pid, running := s.runningPID()
+	if !running {
+		logger.Debug("No existing mihomo process found")
 		return nil
 	}
 
-	var pid int
-	_, err = fmt.Sscanf(string(pidData), "%d", &pid)
-	if err != nil {
-		logger.Warnf("Invalid PID file format, removing: %v", err)
-		os.Remove(pidFile)
-		return nil
-	}
-
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		logger.Debugf("Process %d not found, removing stale PID file", pid)
-		os.Remove(pidFile)
-		return nil
+	logger.Infof("Stopping existing mihomo process (PID: %d)", pid)
+	if _, err := s.terminate(pid, s.markStopping()); err != nil {
+		return fmt.Errorf("failed to stop existing mihomo process: %w", err)
 	}
 
-	err = process.Signal(syscall.Signal(0))
-	if err == nil {
-		logger.Infof("Killing existing mihomo process (PID: %d)", pid)
-		if err := process.Kill(); err != nil {
-			return fmt.Errorf("failed to kill existing mihomo process: %w", err)
-		}
-		if err := os.Remove(pidFile); err != nil {
-			return fmt.Errorf("failed to remove old pid file: %w", err)
-		}
-		logger.Info("Existing mihomo process killed successfully")
+	pidFile := filepath.Join(s.mihomoConfig().WorkingDir, "mihomo.pid")
+	if err := os.Remove(pidFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove old pid file: %w", err)
 	}
+	logger.Info("Existing mihomo process stopped successfully")
 
 	return nil
 }
 
+// Start starts mihomo. While another start is running it waits for that
+// one instead; any other operation in progress fails the call with
+// ErrOperationInProgress. Stop and Restart behave the same way.
 func (s *MihomoService) Start() error {
-	logger.Info("Starting mihomo service")
+	return s.operate("start", opCoalesce, func() error {
+		logger.Info("Starting mihomo service")
+		s.resetSupervisor()
+		return s.start(false)
+	})
+}
+
+// start launches mihomo under the supervisor. A restart after a crash keeps
+// the log file, which holds the reason of the crash, and the saved state.
+func (s *MihomoService) start(afterCrash bool) (err error) {
+	s.setState(StateStarting, 0, nil)
+	defer func() {
+		if err == nil || errors.Is(err, errRoutingFailed) {
+			return
+		}
+		failed := StateStopped
+		if afterCrash {
+			failed = StateCrashed
+		}
+		s.setStateFrom(StateStarting, failed, 0, err)
+	}()
+
+	cfg := s.mihomoConfig()
 
 	if err := s.killExistingMihomo(); err != nil {
 		logger.Errorf("Failed to kill existing mihomo: %v", err)
@@ -120,10 +136,10 @@ func (s *MihomoService) Start() error {
 		return fmt.Errorf("failed to adjust mihomo config: %w", err)
 	}
 
-	if s.appConfig.Mihomo.LogFile != "" {
-		if _, err := os.Stat(s.appConfig.Mihomo.LogFile); err == nil {
+	if cfg.LogFile != "" && !afterCrash {
+		if _, err := os.Stat(cfg.LogFile); err == nil {
 			logger.Debug("Clearing old mihomo log file")
-			if err := os.Remove(s.appConfig.Mihomo.LogFile); err != nil {
+			if err := os.Remove(cfg.LogFile); err != nil {
 				logger.Warnf("Failed to clear old log file: %v", err)
 				return fmt.Errorf("failed to clear old log file: %w", err)
 			}
@@ -136,13 +152,14 @@ func (s *MihomoService) Start() error {
 		return fmt.Errorf("failed to check routing mode: %w", err)
 	}
 
-	logger.Debugf("Starting mihomo core: %s", s.appConfig.Mihomo.CorePath)
-	cmd := exec.Command(s.appConfig.Mihomo.CorePath,
-		"-d", s.appConfig.Mihomo.WorkingDir,
-		"-f", s.appConfig.Mihomo.ConfigPath)
+	logger.Debugf("Starting mihomo core: %s", cfg.CorePath)
+	cmd := exec.Command(cfg.CorePath,
+		"-d", cfg.WorkingDir,
+		"-f", cfg.ConfigPath)
 
-	if s.appConfig.Mihomo.LogFile != "" {
-		logFile, err := os.OpenFile(s.appConfig.Mihomo.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	var logFile *os.File
+	if cfg.LogFile != "" {
+		logFile, err = os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 		if err != nil {
 			logger.Errorf("Failed to open log file: %v", err)
 			return fmt.Errorf("failed to open log file: %w", err)
@@ -153,14 +170,18 @@ func (s *MihomoService) Start() error {
 
 	err = cmd.Start()
 	if err != nil {
+		if logFile != nil {
+			logFile.Close()
+		}
 		logger.Errorf("Failed to start mihomo: %v", err)
 		return fmt.Errorf("failed to start mihomo: %w", err)
 	}
+	proc := s.supervise(cmd, logFile)
 
-	pidFile := filepath.Join(s.appConfig.Mihomo.WorkingDir, "mihomo.pid")
+	pidFile := filepath.Join(cfg.WorkingDir, "mihomo.pid")
 	err = os.WriteFile(pidFile, []byte(fmt.Sprintf("%d", cmd.Process.Pid)), 0644)
 	if err != nil {
-		cmd.Process.Kill()
+		s.stopProcess(proc)
 		logger.Errorf("Failed to write PID file: %v", err)
 		return fmt.Errorf("failed to write pid file: %w", err)
 	}
@@ -169,84 +190,99 @@ func (s *MihomoService) Start() error {
 	if shouldSetupRouting {
 		logger.Debug("Waiting for mihomo to be ready")
 		if err := s.waitForMihomoReady(); err != nil {
-			cmd.Process.Kill()
+			s.stopProcess(proc)
 			os.Remove(pidFile)
 			logger.Errorf("Mihomo not ready: %v", err)
 			return fmt.Errorf("mihomo not ready: %w", err)
 		}
 
 		logger.Debug("Setting up routing")
-		err = s.nftablesService.SetupRouting(s.appConfig.Mihomo.Routing)
+		err = s.nftablesService.SetupRouting(cfg.Routing)
 		if err != nil {
-			cmd.Process.Kill()
-			os.Remove(pidFile)
+			// The core itself is fine; keep it up so it can be fixed or
+			// stopped from the UI instead of looping on restarts.
 			logger.Errorf("Failed to setup routing: %v", err)
-			return fmt.Errorf("failed to setup routing: %w", err)
+			err = fmt.Errorf("%w: %v", errRoutingFailed, err)
+			s.setState(StateDegraded, cmd.Process.Pid, err)
+			return err
 		}
 	}
 
-	s.appConfig.Mihomo.AutoStart = true
-	if err := s.appConfig.Save(s.configPath); err != nil {
-		logger.Warnf("Failed to save auto_start state: %v", err)
+	if !afterCrash {
+		s.appConfig.Lock()
+		s.appConfig.Mihomo.AutoStart = true
+		if err := s.appConfig.Save(s.configPath); err != nil {
+			logger.Warnf("Failed to save auto_start state: %v", err)
+		}
+		s.appConfig.Unlock()
 	}
 
+	s.setState(StateReady, cmd.Process.Pid, nil)
 	logger.Info("Mihomo service started successfully")
 	return nil
 }
 
 func (s *MihomoService) Stop(saveState bool) error {
+	return s.operate("stop", opCoalesce, func() error {
+		return s.stop(saveState)
+	})
+}
+
+// StopAndWait is Stop for shutdown: it waits for a running operation to
+// finish instead of failing.
+func (s *MihomoService) StopAndWait(saveState bool) error {
+	return s.operate("stop", opWait, func() error {
+		return s.stop(saveState)
+	})
+}
+
+func (s *MihomoService) stop(saveState bool) error {
 	logger.Info("Stopping mihomo service")
 
-	if s.GetStatus() == "stopped" {
+	restartPending := s.resetSupervisor()
+
+	pid, running := s.runningPID()
+	s.refreshState(running)
+	if !running {
+		crashed := s.setStateFrom(StateCrashed, StateStopped, 0, nil)
+		if restartPending || crashed {
+			// Crashed: cancelling the restart and removing the routing
+			// left behind is the stop.
+			logger.Info("Cleaning up after crashed mihomo")
+			s.cleanupAfterStop(saveState)
+			return nil
+		}
 		logger.Warn("Mihomo is already stopped")
 		return fmt.Errorf("mihomo is not running")
 	}
 
-	pidFile := filepath.Join(s.appConfig.Mihomo.WorkingDir, "mihomo.pid")
-	pidData, err := os.ReadFile(pidFile)
-	if err != nil {
-		logger.Errorf("Failed to read PID file: %v", err)
-		return fmt.Errorf("failed to read pid file: %w", err)
-	}
-
-	var pid int
-	_, err = fmt.Sscanf(string(pidData), "%d", &pid)
-	if err != nil {
-		logger.Errorf("Failed to parse PID: %v", err)
-		return fmt.Errorf("failed to parse pid: %w", err)
-	}
-
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		logger.Errorf("Failed to find process %d: %v", pid, err)
-		return fmt.Errorf("failed to find process: %w", err)
-	}
-
-	logger.Debugf("Killing mihomo process (PID: %d)", pid)
-	err = process.Kill()
-	if err != nil {
-		handled := errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH)
+	previous := s.Lifecycle().State
+	s.setState(StateStopping, pid, nil)
+	proc := s.markStopping()
 
-		var pathErr *os.PathError
-		if !handled && errors.As(err, &pathErr) && errors.Is(pathErr.Err, syscall.ESRCH) {
-			handled = true
-		}
-		var sysErr *os.SyscallError
-		if !handled && errors.As(err, &sysErr) && errors.Is(sysErr.Err, syscall.ESRCH) {
-			handled = true
-		}
-		if !handled {
-			logger.Errorf("Failed to kill process: %v", err)
-			return fmt.Errorf("failed to kill process: %w", err)
-		}
+	// Routing is only torn down once the process is gone; until then it
+	// still carries the connections mihomo is closing.
+	if _, err := s.terminate(pid, proc); err != nil {
+		logger.Errorf("Failed to stop mihomo: %v", err)
+		s.setStateFrom(StateStopping, previous, pid, err)
+		return err
 	}
 
-	err = os.Remove(pidFile)
-	if err != nil {
+	pidFile := filepath.Join(s.mihomoConfig().WorkingDir, "mihomo.pid")
+	err := os.Remove(pidFile)
+	if err != nil && !os.IsNotExist(err) {
 		logger.Warnf("Failed to remove PID file: %v", err)
 		return fmt.Errorf("failed to remove pid file: %w", err)
 	}
 
+	s.cleanupAfterStop(saveState)
+	s.setState(StateStopped, 0, nil)
+
+	logger.Info("Mihomo service stopped successfully")
+	return nil
+}
+
+func (s *MihomoService) cleanupAfterStop(saveState bool) {
 	shouldCleanupRouting, err := s.shouldSetupRouting()
 	if err == nil && shouldCleanupRouting {
 		logger.Debug("Cleaning up routing")
@@ -255,44 +291,95 @@ func (s *MihomoService) Stop(saveState bool) error {
 
 	if saveState {
 		logger.Debug("Saving auto_start state to config")
+		s.appConfig.Lock()
 		s.appConfig.Mihomo.AutoStart = false
 		if err := s.appConfig.Save(s.configPath); err != nil {
 			logger.Warnf("Failed to save auto_start state: %v", err)
 		}
+		s.appConfig.Unlock()
 	}
-
-	logger.Info("Mihomo service stopped successfully")
-	return nil
 }
 
 func (s *MihomoService) Restart() error {
+	return s.operate("restart", opCoalesce, s.restart)
+}
+
+func (s *MihomoService) restart() error {
 	logger.Info("Restarting mihomo service")
-	err := s.Stop(false)
+	err := s.stop(false)
 	if err != nil && s.GetStatus() != "stopped" {
 		logger.Errorf("Failed to stop mihomo: %v", err)
 		return fmt.Errorf("failed to stop mihomo: %w", err)
 	}
 
-	return s.Start()
+	s.resetSupervisor()
+	return s.start(false)
 }
 
+// GetAppConfig returns a copy of the mihomo settings.
 func (s *MihomoService) GetAppConfig() *config.MihomoConfig {
-	return &s.appConfig.Mihomo
+	cfg := s.mihomoConfig()
+	return &cfg
+}
+
+// mihomoConfig returns the current mihomo settings. The app config is
+// changed by requests running alongside, so it is never read in place.
+func (s *MihomoService) mihomoConfig() config.MihomoConfig {
+	return s.appConfig.Snapshot().Mihomo
 }
 
 func (s *MihomoService) UpdateAppConfig(newConfig *config.MihomoConfig) error {
-	s.appConfig.Mihomo = *newConfig
+	return s.operate("update config", opReject, func() error {
+		s.appConfig.Lock()
+		s.appConfig.Mihomo = *newConfig
+		s.appConfig.Unlock()
 
-	if s.GetStatus() == "running" {
-		return s.Restart()
-	}
+		if s.GetStatus() == "running" {
+			return s.restart()
+		}
+		return nil
+	})
+}
 
-	return nil
+// ChangeConfig applies update to the app config and saves it, as one
+// operation with no start, stop or other change in between. When update
+// returns true a running mihomo is restarted; the returned bool reports
+// whether that happened.
+func (s *MihomoService) ChangeConfig(update func(cfg *config.Config) bool) (bool, error) {
+	restarted := false
+	err := s.operate("update config", opReject, func() error {
+		s.appConfig.Lock()
+		needsRestart := update(s.appConfig)
+		err := s.appConfig.Save(s.configPath)
+		s.appConfig.Unlock()
+		if err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+
+		if needsRestart && s.GetStatus() == "running" {
+			if err := s.restart(); err != nil {
+				return fmt.Errorf("config updated but failed to restart mihomo: %w", err)
+			}
+			restarted = true
+		}
+		return nil
+	})
+	return restarted, err
+}
+
+// SetActiveConfig switches mihomo to configPath and saves the app config.
+// A running mihomo is restarted when auto_restart is enabled; the returned
+// bool reports whether that happened.
+func (s *MihomoService) SetActiveConfig(configPath string) (bool, error) {
+	return s.ChangeConfig(func(cfg *config.Config) bool {
+		cfg.Mihomo.ConfigPath = configPath
+		return cfg.Mihomo.AutoRestart
+	})
 }
 
 func (s *MihomoService) RestoreState() error {
 	logger.Debug("Checking auto_start state")
-	if s.appConfig.Mihomo.AutoStart {
+	if s.mihomoConfig().AutoStart {
 		logger.Info("Auto-start is enabled, checking mihomo status")
 		if s.GetStatus() == "stopped" {
 			logger.Info("Mihomo is stopped, starting automatically")
@@ -306,7 +393,7 @@ func (s *MihomoService) RestoreState() error {
 }
 
 func (s *MihomoService) shouldSetupRouting() (bool, error) {
-	routing := s.appConfig.Mihomo.Routing
+	routing := s.mihomoConfig().Routing
 
 	if routing.TCP != config.RoutingModeDisable || routing.UDP != config.RoutingModeDisable {
 		return true, nil
@@ -316,13 +403,13 @@ func (s *MihomoService) shouldSetupRouting() (bool, error) {
 }
 
 func (s *MihomoService) waitForMihomoReady() error {
-	pidFile := filepath.Join(s.appConfig.Mihomo.WorkingDir, "mihomo.pid")
 	maxWait := 10 * time.Second
 	checkInterval := 500 * time.Millisecond
 	elapsed := time.Duration(0)
+	cfg := s.mihomoConfig()
 
-	needTUN := s.appConfig.Mihomo.Routing.TCP == config.RoutingModeTUN ||
-		s.appConfig.Mihomo.Routing.UDP == config.RoutingModeTUN
+	needTUN := cfg.Routing.TCP == config.RoutingModeTUN ||
+		cfg.Routing.UDP == config.RoutingModeTUN
 
 	if needTUN {
 		logger.Debug("Waiting for TUN interface to be ready")
@@ -331,35 +418,25 @@ func (s *MihomoService) waitForMihomoReady() error {
 	}
 
 	for elapsed < maxWait {
-		pidData, err := os.ReadFile(pidFile)
-		if err == nil {
-			var pid int
-			if _, err := fmt.Sscanf(string(pidData), "%d", &pid); err == nil {
-				process, err := os.FindProcess(pid)
-				if err == nil {
-					if err := process.Signal(syscall.Signal(0)); err == nil {
-						if needTUN {
-							tunDevice := s.appConfig.Mihomo.Routing.TunDevice
-							if tunDevice == "" {
-								tunDevice = "Meta"
-							}
-							_, err := netlink.LinkByName(tunDevice)
-							if err == nil {
-								logger.Info("TUN interface is ready")
-								return nil
-							}
-							logger.Debugf("TUN interface not ready yet, elapsed: %v", elapsed)
-						} else {
-							logger.Info("Mihomo process is ready")
-							return nil
-						}
-					} else {
-						logger.Error("Mihomo process died unexpectedly")
-						return fmt.Errorf("mihomo process died")
-					}
-				}
-			}
+		if _, running := s.runningPID(); !running {
+			logger.Error("Mihomo process died unexpectedly")
+			return fmt.Errorf("mihomo process died")
+		}
+
+		if !needTUN {
+			logger.Info("Mihomo process is ready")
+			return nil
+		}
+
+		tunDevice := cfg.Routing.TunDevice
+		if tunDevice == "" {
+			tunDevice = "Meta"
+		}
+		if _, err := netlink.LinkByName(tunDevice); err == nil {
+			logger.Info("TUN interface is ready")
+			return nil
 		}
+		logger.Debugf("TUN interface not ready yet, elapsed: %v", elapsed)
 
 		time.Sleep(checkInterval)
 		elapsed += checkInterval
@@ -374,10 +451,11 @@ func (s *MihomoService) waitForMihomoReady() error {
 }
 
 func (s *MihomoService) adjustMihomoConfig() error {
-	routing := s.appConfig.Mihomo.Routing
+	cfg := s.mihomoConfig()
+	routing := cfg.Routing
 	needTUN := routing.TCP == config.RoutingModeTUN || routing.UDP == config.RoutingModeTUN
 
-	configData, err := os.ReadFile(s.appConfig.Mihomo.ConfigPath)
+	configData, err := os.ReadFile(cfg.ConfigPath)
 	if err != nil {
 		return fmt.Errorf("failed to read mihomo config: %w", err)
 	}
@@ -385,7 +463,7 @@ func (s *MihomoService) adjustMihomoConfig() error {
 	configStr := string(configData)
 
 	if needTUN {
-		tunDevice := s.appConfig.Mihomo.Routing.TunDevice
+		tunDevice := cfg.Routing.TunDevice
 		if tunDevice == "" {
 			tunDevice = "Meta"
 		}
@@ -394,7 +472,7 @@ func (s *MihomoService) adjustMihomoConfig() error {
 		configStr = ensureTUNDisabled(configStr)
 	}
 
-	err = os.WriteFile(s.appConfig.Mihomo.ConfigPath, []byte(configStr), 0644)
+	err = os.WriteFile(cfg.ConfigPath, []byte(configStr), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write mihomo config: %w", err)
 	}
@@ -592,11 +670,12 @@ func replaceInString(s, old, new string) string {
 }
 
 func (s *MihomoService) GetLogs(lines int) ([]string, error) {
-	if s.appConfig.Mihomo.LogFile == "" {
+	cfg := s.mihomoConfig()
+	if cfg.LogFile == "" {
 		return nil, fmt.Errorf("log file not configured")
 	}
 
-	data, err := os.ReadFile(s.appConfig.Mihomo.LogFile)
+	data, err := os.ReadFile(cfg.LogFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read log file: %w", err)
 	}
@@ -612,11 +691,12 @@ func (s *MihomoService) GetLogs(lines int) ([]string, error) {
 }
 
 func (s *MihomoService) ClearLogs() error {
-	if s.appConfig.Mihomo.LogFile == "" {
+	cfg := s.mihomoConfig()
+	if cfg.LogFile == "" {
 		return fmt.Errorf("log file not configured")
 	}
 
-	file, err := os.OpenFile(s.appConfig.Mihomo.LogFile, os.O_WRONLY|os.O_TRUNC, 0644)
+	file, err := os.OpenFile(cfg.LogFile, os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to clear log file: %w", err)
 	}
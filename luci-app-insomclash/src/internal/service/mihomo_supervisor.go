@@ -0,0 +1,324 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"fusiontunx/pkg/logger"
+)
+
+const (
+	defaultBackoffInitial = 1 * time.Second
+	defaultBackoffMax     = 60 * time.Second
+	defaultMaxRestarts    = 5
+	defaultCrashWindow    = 5 * time.Minute
+)
+
+// ExitStatus describes how a mihomo process ended. Code is -1 when the
+// process was killed by a signal. Expected is set when fusiontunx stopped it.
+type ExitStatus struct {
+	PID      int       `json:"pid"`
+	Code     int       `json:"code"`
+	Signal   string    `json:"signal,omitempty"`
+	Time     time.Time `json:"time"`
+	Uptime   int64     `json:"uptime"`
+	Expected bool      `json:"expected"`
+}
+
+func (e ExitStatus) String() string {
+	if e.Signal != "" {
+		return fmt.Sprintf("killed by signal %s after %ds", e.Signal, e.Uptime)
+	}
+	return fmt.Sprintf("exit code %d after %ds", e.Code, e.Uptime)
+}
+
+// SupervisorStatus reports the crash restarts of mihomo. Restarts counts the
+// automatic restarts within the crash window.
+type SupervisorStatus struct {
+	Restarts    int         `json:"restarts"`
+	NextRestart *time.Time  `json:"next_restart,omitempty"`
+	GaveUp      bool        `json:"gave_up"`
+	LastExit    *ExitStatus `json:"last_exit,omitempty"`
+}
+
+// mihomoProcess is a mihomo child started by this service. stopping is
+// guarded by MihomoService.mu and marks an exit as intended.
+type mihomoProcess struct {
+	cmd      *exec.Cmd
+	logFile  *os.File
+	started  time.Time
+	stopping bool
+	done     chan struct{}
+}
+
+// supervise starts reaping cmd in the background and makes it the current
+// process.
+func (s *MihomoService) supervise(cmd *exec.Cmd, logFile *os.File) *mihomoProcess {
+	proc := &mihomoProcess{
+		cmd:     cmd,
+		logFile: logFile,
+		started: time.Now(),
+		done:    make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.proc = proc
+	s.mu.Unlock()
+
+	go s.wait(proc)
+	return proc
+}
+
+func (s *MihomoService) wait(proc *mihomoProcess) {
+	proc.cmd.Wait()
+	if proc.logFile != nil {
+		proc.logFile.Close()
+	}
+
+	status := ExitStatus{
+		PID:    proc.cmd.Process.Pid,
+		Code:   -1,
+		Time:   time.Now(),
+		Uptime: int64(time.Since(proc.started).Seconds()),
+	}
+	if state := proc.cmd.ProcessState; state != nil {
+		status.Code = state.ExitCode()
+		if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			status.Signal = ws.Signal().String()
+		}
+	}
+
+	s.mu.Lock()
+	status.Expected = proc.stopping || s.proc != proc
+	if s.proc == proc {
+		s.proc = nil
+	}
+	s.lastExit = &status
+	if !status.Expected {
+		s.setStateLocked(StateCrashed, 0, fmt.Errorf("mihomo exited unexpectedly: %s", status))
+	}
+	s.mu.Unlock()
+	close(proc.done)
+
+	if status.Expected {
+		logger.Infof("Mihomo process %d exited (%s)", status.PID, status)
+		return
+	}
+
+	logger.Errorf("Mihomo process %d exited unexpectedly (%s)", status.PID, status)
+	s.removePIDFile(status.PID)
+	s.scheduleRestart()
+}
+
+// scheduleRestart restarts mihomo after the backoff delay, or gives up when
+// auto_restart is off or the crash loop limit is reached. It does nothing
+// while a restart is pending or after giving up, since a core dying during a
+// restart after crash is reported both by wait and by restartAfterCrash.
+func (s *MihomoService) scheduleRestart() {
+	cfg := s.mihomoConfig()
+	policy := cfg.Supervisor
+
+	maxRestarts := policy.MaxRestarts
+	if maxRestarts <= 0 {
+		maxRestarts = defaultMaxRestarts
+	}
+	window := seconds(policy.Window, defaultCrashWindow)
+	backoff := seconds(policy.BackoffInitial, defaultBackoffInitial)
+	backoffMax := seconds(policy.BackoffMax, defaultBackoffMax)
+
+	s.mu.Lock()
+	if s.restartTimer != nil || s.gaveUp {
+		s.mu.Unlock()
+		return
+	}
+
+	if !cfg.AutoRestart {
+		s.gaveUp = true
+		s.mu.Unlock()
+		s.giveUp("auto_restart is disabled")
+		return
+	}
+
+	now := time.Now()
+	recent := s.restarts[:0]
+	for _, t := range s.restarts {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	s.restarts = recent
+
+	if len(s.restarts) >= maxRestarts {
+		s.gaveUp = true
+		s.mu.Unlock()
+		s.giveUp(fmt.Sprintf("%d restarts within %s", len(recent), window))
+		return
+	}
+
+	for i := 0; i < len(s.restarts) && backoff < backoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > backoffMax {
+		backoff = backoffMax
+	}
+
+	due := now.Add(backoff)
+	s.restarts = append(s.restarts, now)
+	s.nextRestart = due
+	s.restartTimer = time.AfterFunc(backoff, func() { s.restartAfterCrash(due) })
+	s.mu.Unlock()
+
+	logger.Warnf("Restarting mihomo in %s (restart %d of %d)", backoff, len(recent)+1, maxRestarts)
+}
+
+// restartAfterCrash runs the restart scheduled for due, unless it has been
+// cancelled or replaced since.
+func (s *MihomoService) restartAfterCrash(due time.Time) {
+	cancelled := false
+	err := s.operate("restart after crash", opWait, func() error {
+		s.mu.Lock()
+		if s.restartTimer == nil || !s.nextRestart.Equal(due) {
+			// Cancelled by Start or Stop.
+			cancelled = true
+			s.mu.Unlock()
+			return nil
+		}
+		s.restartTimer = nil
+		s.nextRestart = time.Time{}
+		s.mu.Unlock()
+
+		logger.Info("Restarting mihomo after crash")
+		return s.start(true)
+	})
+	if cancelled || err == nil {
+		return
+	}
+
+	logger.Errorf("Failed to restart mihomo: %v", err)
+	if !errors.Is(err, errRoutingFailed) {
+		s.scheduleRestart()
+	}
+}
+
+// giveUp stops restarting and, unless the policy keeps them, removes the
+// routing rules so traffic no longer goes to a dead mihomo.
+func (s *MihomoService) giveUp(reason string) {
+	s.mu.Lock()
+	s.gaveUp = true
+	s.mu.Unlock()
+
+	logger.Errorf("Not restarting mihomo: %s", reason)
+
+	if shouldCleanup, _ := s.shouldSetupRouting(); !shouldCleanup {
+		return
+	}
+	if s.mihomoConfig().Supervisor.KeepRouting {
+		logger.Warn("Keeping routing rules after mihomo failure (supervisor.keep_routing)")
+		return
+	}
+
+	s.operate("cleanup after crash", opWait, func() error {
+		// A manual start may have brought mihomo back meanwhile.
+		if s.Lifecycle().State != StateCrashed {
+			return nil
+		}
+
+		logger.Info("Cleaning up routing after mihomo failure")
+		if err := s.nftablesService.CleanupTUNRouting(); err != nil {
+			logger.Errorf("Failed to clean up routing: %v", err)
+		}
+		return nil
+	})
+}
+
+// resetSupervisor cancels a pending restart and clears the crash history, as
+// done on a manual start or stop. It reports whether a restart was pending.
+func (s *MihomoService) resetSupervisor() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := s.restartTimer != nil
+	if pending {
+		s.restartTimer.Stop()
+		s.restartTimer = nil
+	}
+	s.nextRestart = time.Time{}
+	s.restarts = nil
+	s.gaveUp = false
+	return pending
+}
+
+// markStopping flags the current process so its exit is not treated as a
+// crash, and returns it.
+func (s *MihomoService) markStopping() *mihomoProcess {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.proc != nil {
+		s.proc.stopping = true
+	}
+	return s.proc
+}
+
+// stopProcess stops a process that failed to come up.
+func (s *MihomoService) stopProcess(proc *mihomoProcess) {
+	s.mu.Lock()
+	proc.stopping = true
+	s.mu.Unlock()
+
+	if _, err := s.terminate(proc.cmd.Process.Pid, proc); err != nil {
+		logger.Errorf("Failed to stop mihomo: %v", err)
+	}
+}
+
+// waitExit waits until the supervisor has reaped proc.
+func waitExit(proc *mihomoProcess, timeout time.Duration) bool {
+	select {
+	case <-proc.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (s *MihomoService) removePIDFile(pid int) {
+	pidFile := filepath.Join(s.mihomoConfig().WorkingDir, "mihomo.pid")
+	pidData, err := os.ReadFile(pidFile)
+	if err != nil {
+		return
+	}
+
+	var filePID int
+	if _, err := fmt.Sscanf(string(pidData), "%d", &filePID); err == nil && filePID == pid {
+		os.Remove(pidFile)
+	}
+}
+
+// Supervisor returns the crash restart state.
+func (s *MihomoService) Supervisor() SupervisorStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := SupervisorStatus{
+		Restarts: len(s.restarts),
+		GaveUp:   s.gaveUp,
+		LastExit: s.lastExit,
+	}
+	if !s.nextRestart.IsZero() {
+		next := s.nextRestart
+		status.NextRestart = &next
+	}
+	return status
+}
+
+func seconds(value int, fallback time.Duration) time.Duration {
+	if value <= 0 {
+		return fallback
+	}
+	return time.Duration(value) * time.Second
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"fusiontunx/pkg/logger"
+)
+
+const (
+	defaultStopTimeout = 10 * time.Second
+	// killTimeout bounds the wait after SIGKILL, which cannot be ignored
+	// but still takes a moment to be delivered and reaped.
+	killTimeout = 5 * time.Second
+
+	StopMethodSIGTERM = "sigterm"
+	StopMethodSIGKILL = "sigkill"
+)
+
+// EventMihomoStop is published with a StopResult whenever the core has been
+// stopped.
+const EventMihomoStop = "mihomo.stop"
+
+// StopResult reports how the core was stopped. Method is StopMethodSIGTERM
+// when it exited on its own within the stop timeout and StopMethodSIGKILL
+// when it had to be killed.
+type StopResult struct {
+	PID      int       `json:"pid"`
+	Method   string    `json:"method"`
+	Duration int64     `json:"duration_ms"`
+	Time     time.Time `json:"time"`
+}
+
+// terminate stops the core gracefully so it can save store-selected and the
+// fake-ip cache and close connections: SIGTERM first, SIGKILL once
+// mihomo.stop_timeout has passed. It returns only when the process is gone,
+// so routing may be torn down right after.
+func (s *MihomoService) terminate(pid int, proc *mihomoProcess) (StopResult, error) {
+	result := StopResult{PID: pid, Method: StopMethodSIGTERM}
+	started := time.Now()
+
+	// Signal our own child through its handle, which knows once it has been
+	// reaped and so never hits a reused pid.
+	var process *os.Process
+	if proc != nil && proc.cmd.Process.Pid == pid {
+		process = proc.cmd.Process
+	} else {
+		proc = nil
+		found, err := os.FindProcess(pid)
+		if err != nil {
+			return result, fmt.Errorf("failed to find process: %w", err)
+		}
+		process = found
+	}
+
+	timeout := seconds(s.mihomoConfig().StopTimeout, defaultStopTimeout)
+
+	logger.Debugf("Sending SIGTERM to mihomo process (PID: %d)", pid)
+	if err := process.Signal(syscall.SIGTERM); err != nil && !processGone(err) {
+		return result, fmt.Errorf("failed to terminate process: %w", err)
+	}
+
+	if !waitProcessExit(process, proc, timeout) {
+		logger.Warnf("Mihomo process %d did not exit within %s, killing it", pid, timeout)
+		result.Method = StopMethodSIGKILL
+		if err := process.Kill(); err != nil && !processGone(err) {
+			return result, fmt.Errorf("failed to kill process: %w", err)
+		}
+		if !waitProcessExit(process, proc, killTimeout) {
+			return result, fmt.Errorf("mihomo process %d did not exit after SIGKILL", pid)
+		}
+	}
+
+	result.Time = time.Now()
+	result.Duration = result.Time.Sub(started).Milliseconds()
+	logger.Infof("Mihomo process %d stopped by %s after %dms", pid, result.Method, result.Duration)
+
+	s.mu.Lock()
+	s.lastStop = &result
+	s.mu.Unlock()
+	if s.events != nil {
+		s.events.Publish(EventMihomoStop, result)
+	}
+	return result, nil
+}
+
+// waitProcessExit waits for the process to exit. Our own child is waited on
+// through the supervisor; a core adopted from a previous fusiontunx instance
+// is polled.
+func waitProcessExit(process *os.Process, proc *mihomoProcess, timeout time.Duration) bool {
+	if proc != nil {
+		return waitExit(proc, timeout)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := process.Signal(syscall.Signal(0)); err != nil {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// processGone reports whether a signal failed because the process has
+// already exited.
+func processGone(err error) bool {
+	return errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH)
+}
@@ -0,0 +1,639 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"fusiontunx/internal/converter"
+	"fusiontunx/pkg/config"
+	"fusiontunx/pkg/logger"
+)
+
+var (
+	ErrSubscriptionNotFound   = errors.New("subscription not found")
+	ErrSubscriptionRefreshing = errors.New("subscription refresh already in progress")
+	ErrInvalidSubscription    = errors.New("invalid subscription")
+)
+
+const subscriptionCheckInterval = time.Minute
+
+// SubscriptionStatus is the runtime state of a subscription. It is kept in
+// memory only; the last successful update survives restarts through the
+// provider's subscription info file.
+type SubscriptionStatus struct {
+	LastAttempt int64                       `json:"last_attempt,omitempty"`
+	LastSuccess int64                       `json:"last_success,omitempty"`
+	LastError   string                      `json:"last_error,omitempty"`
+	Format      string                      `json:"format,omitempty"`
+	Count       int                         `json:"count"`
+	Refreshing  bool                        `json:"refreshing"`
+	Info        *converter.SubscriptionInfo `json:"info,omitempty"`
+}
+
+type Subscription struct {
+	config.SubscriptionConfig
+	Status SubscriptionStatus `json:"status"`
+}
+
+type SubscriptionService struct {
+	appConfig     *config.Config
+	configPath    string
+	mihomoService *MihomoService
+
+	// mu guards status and appConfig.Subscriptions, which only this service
+	// changes; the rest of appConfig is read through Snapshot.
+	mu     sync.Mutex
+	status map[string]*SubscriptionStatus
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewSubscriptionService(appConfig *config.Config, configPath string, mihomoService *MihomoService) *SubscriptionService {
+	return &SubscriptionService{
+		appConfig:     appConfig,
+		configPath:    configPath,
+		mihomoService: mihomoService,
+		status:        make(map[string]*SubscriptionStatus),
+	}
+}
+
+// Start runs the refresh scheduler until Stop is called.
+func (s *SubscriptionService) Start() {
+	s.mu.Lock()
+	if s.stop != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.stop = make(chan struct{})
+	stop := s.stop
+	for _, sub := range s.appConfig.Subscriptions {
+		s.loadStatus(sub)
+	}
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(subscriptionCheckInterval)
+		defer ticker.Stop()
+
+		s.refreshDue()
+		for {
+			select {
+			case <-ticker.C:
+				s.refreshDue()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *SubscriptionService) Stop() {
+	s.mu.Lock()
+	if s.stop == nil {
+		s.mu.Unlock()
+		return
+	}
+	close(s.stop)
+	s.stop = nil
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *SubscriptionService) List() []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := make([]Subscription, 0, len(s.appConfig.Subscriptions))
+	for _, sub := range s.appConfig.Subscriptions {
+		subs = append(subs, Subscription{SubscriptionConfig: sub, Status: *s.statusOf(sub.ID)})
+	}
+	return subs
+}
+
+func (s *SubscriptionService) Get(id string) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.indexOf(id)
+	if idx == -1 {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	sub := s.appConfig.Subscriptions[idx]
+	return &Subscription{SubscriptionConfig: sub, Status: *s.statusOf(id)}, nil
+}
+
+func (s *SubscriptionService) Add(sub config.SubscriptionConfig) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := newSubscriptionID()
+	if err != nil {
+		return nil, err
+	}
+	sub.ID = id
+
+	if err := s.validate(sub); err != nil {
+		return nil, err
+	}
+
+	subs := append([]config.SubscriptionConfig{}, s.appConfig.Subscriptions...)
+	if err := s.saveSubscriptions(append(subs, sub)); err != nil {
+		return nil, err
+	}
+
+	s.loadStatus(sub)
+	logger.Infof("Subscription %s (%s) added", sub.Name, sub.ID)
+
+	return &Subscription{SubscriptionConfig: sub, Status: *s.statusOf(id)}, nil
+}
+
+// Update replaces the subscription's settings. It is refused while the
+// subscription is being refreshed.
+func (s *SubscriptionService) Update(id string, sub config.SubscriptionConfig) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.indexOf(id)
+	if idx == -1 {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	// A running refresh fetched with the old settings and would store its
+	// result over the new status and provider.
+	if s.statusOf(id).Refreshing {
+		return nil, ErrSubscriptionRefreshing
+	}
+
+	sub.ID = id
+	if err := s.validate(sub); err != nil {
+		return nil, err
+	}
+
+	old := s.appConfig.Subscriptions[idx]
+	subs := append([]config.SubscriptionConfig{}, s.appConfig.Subscriptions...)
+	subs[idx] = sub
+	if err := s.saveSubscriptions(subs); err != nil {
+		return nil, err
+	}
+
+	// A new URL or provider file makes the previous result meaningless.
+	if old.URL != sub.URL || old.Provider != sub.Provider {
+		delete(s.status, id)
+		s.loadStatus(sub)
+	}
+
+	// The cached provider was built with the old settings, so the next
+	// refresh must not be answered with 304.
+	if old.URL != sub.URL || old.UserAgent != sub.UserAgent || !reflect.DeepEqual(old.Process, sub.Process) {
+		s.clearFetchCache(sub.Provider)
+	}
+
+	return &Subscription{SubscriptionConfig: sub, Status: *s.statusOf(id)}, nil
+}
+
+// Delete removes the subscription. The provider file is kept unless
+// removeProvider is set, since the mihomo config may still reference it.
+// It is refused while the subscription is being refreshed.
+func (s *SubscriptionService) Delete(id string, removeProvider bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.indexOf(id)
+	if idx == -1 {
+		return ErrSubscriptionNotFound
+	}
+
+	// A running refresh writes the provider and reloads it without holding
+	// the lock, and would bring back the files removed here.
+	if s.statusOf(id).Refreshing {
+		return ErrSubscriptionRefreshing
+	}
+
+	sub := s.appConfig.Subscriptions[idx]
+	subs := append([]config.SubscriptionConfig{}, s.appConfig.Subscriptions[:idx]...)
+	subs = append(subs, s.appConfig.Subscriptions[idx+1:]...)
+
+	if err := s.saveSubscriptions(subs); err != nil {
+		return err
+	}
+	delete(s.status, id)
+
+	if removeProvider {
+		providerPath := s.providerPath(sub.Provider)
+		if err := os.Remove(providerPath); err != nil && !os.IsNotExist(err) {
+			logger.Warnf("Failed to remove provider %s: %v", providerPath, err)
+		}
+		if err := os.Remove(converter.SubscriptionInfoPath(providerPath)); err != nil && !os.IsNotExist(err) {
+			logger.Warnf("Failed to remove subscription info for %s: %v", providerPath, err)
+		}
+	}
+
+	logger.Infof("Subscription %s (%s) deleted", sub.Name, sub.ID)
+	return nil
+}
+
+// saveSubscriptions replaces the subscription list and saves the app config,
+// keeping the previous list when saving fails.
+func (s *SubscriptionService) saveSubscriptions(subs []config.SubscriptionConfig) error {
+	s.appConfig.Lock()
+	defer s.appConfig.Unlock()
+
+	old := s.appConfig.Subscriptions
+	s.appConfig.Subscriptions = subs
+	if err := s.appConfig.Save(s.configPath); err != nil {
+		s.appConfig.Subscriptions = old
+		return fmt.Errorf("failed to save app config: %w", err)
+	}
+	return nil
+}
+
+// Refresh fetches the subscription now and rewrites its provider file. On
+// failure the previous provider file is left untouched.
+func (s *SubscriptionService) Refresh(id string) (*Subscription, error) {
+	s.mu.Lock()
+	idx := s.indexOf(id)
+	if idx == -1 {
+		s.mu.Unlock()
+		return nil, ErrSubscriptionNotFound
+	}
+
+	sub := s.appConfig.Subscriptions[idx]
+	status := s.statusOf(id)
+	if status.Refreshing {
+		s.mu.Unlock()
+		return nil, ErrSubscriptionRefreshing
+	}
+	status.Refreshing = true
+	status.LastAttempt = time.Now().Unix()
+	s.mu.Unlock()
+
+	result, err := s.fetch(sub)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The entry may have been deleted while fetching.
+	if idx = s.indexOf(id); idx == -1 {
+		return nil, ErrSubscriptionNotFound
+	}
+	sub = s.appConfig.Subscriptions[idx]
+
+	status = s.statusOf(id)
+	status.Refreshing = false
+	if err != nil {
+		status.LastError = err.Error()
+		logger.Errorf("Subscription %s refresh failed, keeping previous provider: %v", sub.Name, err)
+	} else if result.NotModified {
+		status.LastError = ""
+		status.LastSuccess = time.Now().Unix()
+		status.Info = result.Info
+		logger.Infof("Subscription %s not modified, keeping provider", sub.Name)
+	} else {
+		status.LastError = ""
+		status.LastSuccess = time.Now().Unix()
+		status.Format = string(result.Format)
+		status.Count = len(result.Proxies)
+		status.Info = result.Info
+		logger.Infof("Subscription %s refreshed: %d proxies", sub.Name, len(result.Proxies))
+	}
+
+	return &Subscription{SubscriptionConfig: sub, Status: *status}, err
+}
+
+func (s *SubscriptionService) fetch(sub config.SubscriptionConfig) (*converter.Subscription, error) {
+	opts, err := s.FetchOptions(sub.UserAgent, sub.ViaMihomo)
+	if err != nil {
+		return nil, err
+	}
+
+	providerPath := s.providerPath(sub.Provider)
+	cached, _ := converter.LoadSubscriptionInfo(providerPath)
+	if cached != nil {
+		if _, err := os.Stat(providerPath); err == nil {
+			opts.ETag = cached.ETag
+			opts.LastModified = cached.LastModified
+		}
+	}
+
+	result, err := converter.FetchSubscription(sub.URL, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.NotModified {
+		// The provider file is still current; only refresh the metadata.
+		info := result.Info
+		if info == nil {
+			info = cached
+		}
+		if info == nil {
+			info = &converter.SubscriptionInfo{}
+		}
+		info.UpdatedAt = time.Now().Unix()
+		info.ETag = result.ETag
+		info.LastModified = result.LastModified
+		result.Info = info
+		if err := converter.SaveSubscriptionInfo(providerPath, info); err != nil {
+			logger.Warnf("Failed to save subscription info for %s: %v", sub.Name, err)
+		}
+		return result, nil
+	}
+
+	if result.Report.Failed > 0 {
+		logger.Warnf("Subscription %s: %s", sub.Name, result.Report)
+	}
+
+	result.Proxies, err = converter.ProcessProxies(result.Proxies, processOptions(sub.Process))
+	if err != nil {
+		return nil, err
+	}
+
+	// One invalid proxy would make mihomo reject the whole provider.
+	var validation *converter.ValidationReport
+	result.Proxies, validation = converter.DropInvalid(result.Proxies)
+	if validation.Errors > 0 {
+		logger.Warnf("Subscription %s: dropped %v", sub.Name, validation.Err())
+	}
+
+	if len(result.Proxies) == 0 {
+		return nil, errors.New("subscription returned no proxies")
+	}
+
+	content, err := converter.ToMihomoYAML(result.Proxies)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(providerPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create provider directory: %w", err)
+	}
+
+	// Write to a temporary file first so mihomo never reads a partial
+	// provider and a failed write keeps the old one.
+	tmpPath := providerPath + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write provider: %w", err)
+	}
+	if err := os.Rename(tmpPath, providerPath); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to replace provider: %w", err)
+	}
+
+	info := result.Info
+	if info == nil {
+		info = &converter.SubscriptionInfo{UpdatedAt: time.Now().Unix()}
+		result.Info = info
+	}
+	info.ETag = result.ETag
+	info.LastModified = result.LastModified
+	if err := converter.SaveSubscriptionInfo(providerPath, info); err != nil {
+		logger.Warnf("Failed to save subscription info for %s: %v", sub.Name, err)
+	}
+
+	if err := s.reloadProvider(providerPath); err != nil {
+		logger.Warnf("Provider %s updated but mihomo reload failed: %v", sub.Provider, err)
+	}
+
+	return result, nil
+}
+
+// FetchOptions builds the fetch settings from the app config. userAgent
+// overrides the configured one and viaMihomo (or the global setting) routes
+// the request through the running mihomo.
+func (s *SubscriptionService) FetchOptions(userAgent string, viaMihomo bool) (converter.FetchOptions, error) {
+	cfg := s.appConfig.Snapshot()
+	fetch := cfg.Fetch
+
+	opts := converter.FetchOptions{
+		UserAgent:    fetch.UserAgent,
+		Timeout:      time.Duration(fetch.Timeout) * time.Second,
+		MaxSize:      int64(fetch.MaxSize) << 20,
+		MaxRedirects: fetch.MaxRedirects,
+		BlockPrivate: fetch.BlockPrivate,
+	}
+	if userAgent != "" {
+		opts.UserAgent = userAgent
+	}
+
+	if viaMihomo || fetch.ViaMihomo {
+		if s.mihomoService.GetStatus() != "running" {
+			return opts, errors.New("fetching through mihomo requires mihomo to be running")
+		}
+
+		proxyURL, err := config.ParseMihomoProxyURL(cfg.Mihomo.ConfigPath)
+		if err != nil {
+			return opts, err
+		}
+		opts.Proxy = proxyURL
+	}
+
+	return opts, nil
+}
+
+func (s *SubscriptionService) clearFetchCache(provider string) {
+	providerPath := s.providerPath(provider)
+	info, err := converter.LoadSubscriptionInfo(providerPath)
+	if err != nil || (info.ETag == "" && info.LastModified == "") {
+		return
+	}
+
+	info.ETag = ""
+	info.LastModified = ""
+	if err := converter.SaveSubscriptionInfo(providerPath, info); err != nil {
+		logger.Warnf("Failed to clear fetch cache for %s: %v", provider, err)
+	}
+}
+
+func (s *SubscriptionService) refreshDue() {
+	s.mu.Lock()
+	var due []string
+	now := time.Now()
+	for _, sub := range s.appConfig.Subscriptions {
+		if !sub.Enabled || sub.Interval <= 0 {
+			continue
+		}
+
+		status := s.statusOf(sub.ID)
+		if status.Refreshing {
+			continue
+		}
+
+		interval := time.Duration(sub.Interval) * time.Minute
+		if now.Sub(time.Unix(status.LastAttempt, 0)) >= interval {
+			due = append(due, sub.ID)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, id := range due {
+		s.Refresh(id)
+	}
+}
+
+// reloadProvider asks a running mihomo to re-read every proxy provider that
+// points at providerPath.
+func (s *SubscriptionService) reloadProvider(providerPath string) error {
+	if s.mihomoService.GetStatus() != "running" {
+		return nil
+	}
+
+	mihomo := s.appConfig.Snapshot().Mihomo
+	providers, err := config.ParseMihomoProxyProviders(mihomo.ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	for name, provider := range providers {
+		path := provider.Path
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(mihomo.WorkingDir, path)
+		}
+		if filepath.Clean(path) != filepath.Clean(providerPath) {
+			continue
+		}
+
+		req, err := http.NewRequest(http.MethodPut, mihomo.APIURL+"/providers/proxies/"+url.PathEscape(name), nil)
+		if err != nil {
+			return err
+		}
+		if mihomo.APISecret != "" {
+			req.Header.Set("Authorization", "Bearer "+mihomo.APISecret)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("mihomo returned status %d for provider %s", resp.StatusCode, name)
+		}
+		logger.Infof("Reloaded mihomo proxy provider %s", name)
+	}
+
+	return nil
+}
+
+func (s *SubscriptionService) validate(sub config.SubscriptionConfig) error {
+	if strings.TrimSpace(sub.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSubscription)
+	}
+
+	if !strings.HasPrefix(sub.URL, "http://") && !strings.HasPrefix(sub.URL, "https://") {
+		return fmt.Errorf("%w: url must be an http(s) subscription", ErrInvalidSubscription)
+	}
+
+	if sub.Interval < 0 {
+		return fmt.Errorf("%w: interval must not be negative", ErrInvalidSubscription)
+	}
+
+	if _, err := converter.ProcessProxies(nil, processOptions(sub.Process)); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSubscription, err)
+	}
+
+	ext := filepath.Ext(sub.Provider)
+	if ext != ".yaml" && ext != ".yml" {
+		return fmt.Errorf("%w: provider must be a yaml filename", ErrInvalidSubscription)
+	}
+	if filepath.Base(sub.Provider) != sub.Provider || strings.HasPrefix(sub.Provider, ".") {
+		return fmt.Errorf("%w: provider must be a plain filename", ErrInvalidSubscription)
+	}
+
+	for _, other := range s.appConfig.Subscriptions {
+		if other.ID != sub.ID && other.Provider == sub.Provider {
+			return fmt.Errorf("%w: provider %s is already used by subscription %s", ErrInvalidSubscription, sub.Provider, other.Name)
+		}
+	}
+
+	return nil
+}
+
+// loadStatus seeds the status from the provider's info file so a restart
+// does not refetch every subscription at once.
+func (s *SubscriptionService) loadStatus(sub config.SubscriptionConfig) {
+	status := s.statusOf(sub.ID)
+	if status.LastAttempt != 0 {
+		return
+	}
+
+	info, err := converter.LoadSubscriptionInfo(s.providerPath(sub.Provider))
+	if err != nil {
+		return
+	}
+
+	status.LastAttempt = info.UpdatedAt
+	status.LastSuccess = info.UpdatedAt
+	status.Info = info
+}
+
+func (s *SubscriptionService) statusOf(id string) *SubscriptionStatus {
+	status, ok := s.status[id]
+	if !ok {
+		status = &SubscriptionStatus{}
+		s.status[id] = status
+	}
+	return status
+}
+
+func (s *SubscriptionService) indexOf(id string) int {
+	for i, sub := range s.appConfig.Subscriptions {
+		if sub.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *SubscriptionService) providerPath(filename string) string {
+	return filepath.Join(s.appConfig.Snapshot().Mihomo.WorkingDir, "proxy_providers", filename)
+}
+
+func processOptions(cfg *config.ProcessConfig) *converter.ProcessOptions {
+	if cfg == nil {
+		return nil
+	}
+
+	opts := &converter.ProcessOptions{
+		SkipInfoNodes: cfg.SkipInfoNodes,
+		IncludeName:   cfg.IncludeName,
+		ExcludeName:   cfg.ExcludeName,
+		IncludeType:   cfg.IncludeType,
+		ExcludeType:   cfg.ExcludeType,
+		IncludeServer: cfg.IncludeServer,
+		ExcludeServer: cfg.ExcludeServer,
+		Dedupe:        cfg.Dedupe,
+		CountryTag:    cfg.CountryTag,
+	}
+	for _, rule := range cfg.Rename {
+		opts.Rename = append(opts.Rename, converter.RenameRule{Pattern: rule.Pattern, Replace: rule.Replace})
+	}
+	return opts
+}
+
+func newSubscriptionID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate subscription id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fusiontunx/internal/converter"
+	"fusiontunx/pkg/config"
+)
+
+func TestDeleteDuringRefreshIsRejected(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Mihomo: config.MihomoConfig{WorkingDir: dir}}
+	s := NewSubscriptionService(cfg, filepath.Join(dir, "fusiontunx.yaml"), nil)
+
+	sub, err := s.Add(config.SubscriptionConfig{
+		Name:     "sub",
+		URL:      "https://example.com/sub",
+		Provider: "sub.yaml",
+	})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	providerPath := s.providerPath(sub.Provider)
+	if err := os.MkdirAll(filepath.Dir(providerPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{providerPath, converter.SubscriptionInfoPath(providerPath)} {
+		if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.mu.Lock()
+	s.statusOf(sub.ID).Refreshing = true
+	s.mu.Unlock()
+
+	if err := s.Delete(sub.ID, true); !errors.Is(err, ErrSubscriptionRefreshing) {
+		t.Errorf("Delete() during Refresh error = %v, want %v", err, ErrSubscriptionRefreshing)
+	}
+	if _, err := s.Get(sub.ID); err != nil {
+		t.Errorf("Get() after rejected Delete error = %v", err)
+	}
+	if _, err := os.Stat(providerPath); err != nil {
+		t.Errorf("provider file after rejected Delete: %v", err)
+	}
+
+	s.mu.Lock()
+	s.statusOf(sub.ID).Refreshing = false
+	s.mu.Unlock()
+
+	if err := s.Delete(sub.ID, true); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := os.Stat(providerPath); !os.IsNotExist(err) {
+		t.Errorf("provider file after Delete: %v, want it removed", err)
+	}
+}
@@ -36,6 +36,33 @@ func Load(path string) (*Config, error) {
 	return config, nil
 }
 
+// Lock serializes changes to the config. Hold it around a change and the
+// Save that persists it, so Save never writes a half-made change.
+func (c *Config) Lock() {
+	c.mu.Lock()
+}
+
+func (c *Config) Unlock() {
+	c.mu.Unlock()
+}
+
+// Snapshot returns a copy of the config taken under the lock, for readers
+// that run alongside changes. The copy shares the subscriptions' process
+// options and must not be modified.
+func (c *Config) Snapshot() *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return &Config{
+		Server:        c.Server,
+		Mihomo:        c.Mihomo,
+		Logging:       c.Logging,
+		API:           c.API,
+		Fetch:         c.Fetch,
+		Subscriptions: append([]SubscriptionConfig(nil), c.Subscriptions...),
+	}
+}
+
 func (c *Config) Save(path string) error {
 	data, err := yaml.Marshal(c)
 	if err != nil {
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
 
@@ -13,6 +14,11 @@ type MihomoExternalConfig struct {
 	Secret             string `yaml:"secret"`
 }
 
+type MihomoProxyProvider struct {
+	Type string `yaml:"type"`
+	Path string `yaml:"path"`
+}
+
 func ParseMihomoConfig(configPath string) (apiURL string, secret string, err error) {
 	data, err := os.ReadFile(configPath)
 	if err != nil {
@@ -39,3 +45,65 @@ func ParseMihomoConfig(configPath string) (apiURL string, secret string, err err
 
 	return apiURL, secret, nil
 }
+
+// ParseMihomoProxyURL returns a URL for the local proxy inbound of a mihomo
+// config, preferring mixed-port, with the first authentication entry as
+// credentials.
+func ParseMihomoProxyURL(configPath string) (string, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read mihomo config: %w", err)
+	}
+
+	var mihomoConfig struct {
+		MixedPort      int      `yaml:"mixed-port"`
+		Port           int      `yaml:"port"`
+		SocksPort      int      `yaml:"socks-port"`
+		Authentication []string `yaml:"authentication"`
+	}
+	if err := yaml.Unmarshal(data, &mihomoConfig); err != nil {
+		return "", fmt.Errorf("failed to parse mihomo config: %w", err)
+	}
+
+	proxyURL := &url.URL{}
+	switch {
+	case mihomoConfig.MixedPort > 0:
+		proxyURL.Scheme = "http"
+		proxyURL.Host = fmt.Sprintf("127.0.0.1:%d", mihomoConfig.MixedPort)
+	case mihomoConfig.Port > 0:
+		proxyURL.Scheme = "http"
+		proxyURL.Host = fmt.Sprintf("127.0.0.1:%d", mihomoConfig.Port)
+	case mihomoConfig.SocksPort > 0:
+		proxyURL.Scheme = "socks5"
+		proxyURL.Host = fmt.Sprintf("127.0.0.1:%d", mihomoConfig.SocksPort)
+	default:
+		return "", fmt.Errorf("mihomo config has no mixed-port, port or socks-port")
+	}
+
+	if len(mihomoConfig.Authentication) > 0 {
+		credentials := strings.SplitN(mihomoConfig.Authentication[0], ":", 2)
+		if len(credentials) == 2 {
+			proxyURL.User = url.UserPassword(credentials[0], credentials[1])
+		}
+	}
+
+	return proxyURL.String(), nil
+}
+
+// ParseMihomoProxyProviders returns the proxy-providers of a mihomo config
+// keyed by provider name.
+func ParseMihomoProxyProviders(configPath string) (map[string]MihomoProxyProvider, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mihomo config: %w", err)
+	}
+
+	var mihomoConfig struct {
+		ProxyProviders map[string]MihomoProxyProvider `yaml:"proxy-providers"`
+	}
+	if err := yaml.Unmarshal(data, &mihomoConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse mihomo config: %w", err)
+	}
+
+	return mihomoConfig.ProxyProviders, nil
+}
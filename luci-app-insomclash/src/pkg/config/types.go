@@ -1,10 +1,16 @@
 package config
 
+import "sync"
+
 type Config struct {
-	Server  ServerConfig  `yaml:"server"`
-	Mihomo  MihomoConfig  `yaml:"mihomo"`
-	Logging LoggingConfig `yaml:"logging"`
-	API     APIConfig     `yaml:"api"`
+	Server        ServerConfig         `yaml:"server"`
+	Mihomo        MihomoConfig         `yaml:"mihomo"`
+	Logging       LoggingConfig        `yaml:"logging"`
+	API           APIConfig            `yaml:"api"`
+	Fetch         FetchConfig          `yaml:"fetch"`
+	Subscriptions []SubscriptionConfig `yaml:"subscriptions,omitempty"`
+
+	mu sync.Mutex
 }
 
 type ServerConfig struct {
@@ -29,15 +35,30 @@ type RoutingConfig struct {
 }
 
 type MihomoConfig struct {
-	CorePath    string        `yaml:"core_path"`
-	ConfigPath  string        `yaml:"config_path"`
-	WorkingDir  string        `yaml:"working_dir"`
-	AutoRestart bool          `yaml:"auto_restart"`
-	AutoStart   bool          `yaml:"auto_start"`
-	LogFile     string        `yaml:"log_file"`
-	APIURL      string        `yaml:"api_url"`
-	APISecret   string        `yaml:"api_secret"`
-	Routing     RoutingConfig `yaml:"routing"`
+	CorePath    string           `yaml:"core_path"`
+	ConfigPath  string           `yaml:"config_path"`
+	WorkingDir  string           `yaml:"working_dir"`
+	AutoRestart bool             `yaml:"auto_restart"`
+	AutoStart   bool             `yaml:"auto_start"`
+	LogFile     string           `yaml:"log_file"`
+	APIURL      string           `yaml:"api_url"`
+	APISecret   string           `yaml:"api_secret"`
+	Routing     RoutingConfig    `yaml:"routing"`
+	Supervisor  SupervisorConfig `yaml:"supervisor"`
+	StopTimeout int              `yaml:"stop_timeout,omitempty"`
+}
+
+// SupervisorConfig is the restart policy for a crashed mihomo while
+// AutoRestart is on. BackoffInitial, BackoffMax and Window are in seconds;
+// zero values use the service defaults. After MaxRestarts restarts within
+// Window the supervisor gives up and removes the routing rules unless
+// KeepRouting is set.
+type SupervisorConfig struct {
+	BackoffInitial int  `yaml:"backoff_initial,omitempty"`
+	BackoffMax     int  `yaml:"backoff_max,omitempty"`
+	MaxRestarts    int  `yaml:"max_restarts,omitempty"`
+	Window         int  `yaml:"window,omitempty"`
+	KeepRouting    bool `yaml:"keep_routing"`
 }
 
 type LoggingConfig struct {
@@ -64,3 +85,50 @@ type CORSConfig struct {
 	AllowCredentials bool     `yaml:"allow_credentials"`
 	MaxAge           int      `yaml:"max_age"`
 }
+
+// FetchConfig controls how subscriptions are downloaded. Timeout is in
+// seconds and MaxSize in MB; zero values use the converter defaults and a
+// negative MaxRedirects disables redirects.
+type FetchConfig struct {
+	UserAgent    string `yaml:"user_agent,omitempty"`
+	Timeout      int    `yaml:"timeout,omitempty"`
+	MaxSize      int    `yaml:"max_size,omitempty"`
+	MaxRedirects int    `yaml:"max_redirects,omitempty"`
+	BlockPrivate bool   `yaml:"block_private"`
+	ViaMihomo    bool   `yaml:"via_mihomo"`
+}
+
+// SubscriptionConfig is a stored subscription that is refreshed into
+// WorkingDir/proxy_providers/<Provider>. Interval is in minutes; zero
+// disables scheduled refresh.
+type SubscriptionConfig struct {
+	ID        string `yaml:"id" json:"id"`
+	Name      string `yaml:"name" json:"name"`
+	URL       string `yaml:"url" json:"url"`
+	UserAgent string `yaml:"user_agent,omitempty" json:"user_agent,omitempty"`
+	Interval  int    `yaml:"interval" json:"interval"`
+	Provider  string `yaml:"provider" json:"provider"`
+	Enabled   bool   `yaml:"enabled" json:"enabled"`
+	ViaMihomo bool   `yaml:"via_mihomo,omitempty" json:"via_mihomo,omitempty"`
+
+	Process *ProcessConfig `yaml:"process,omitempty" json:"process,omitempty"`
+}
+
+// ProcessConfig mirrors converter.ProcessOptions for stored subscriptions.
+type ProcessConfig struct {
+	SkipInfoNodes bool         `yaml:"skip_info_nodes,omitempty" json:"skip_info_nodes,omitempty"`
+	IncludeName   string       `yaml:"include_name,omitempty" json:"include_name,omitempty"`
+	ExcludeName   string       `yaml:"exclude_name,omitempty" json:"exclude_name,omitempty"`
+	IncludeType   string       `yaml:"include_type,omitempty" json:"include_type,omitempty"`
+	ExcludeType   string       `yaml:"exclude_type,omitempty" json:"exclude_type,omitempty"`
+	IncludeServer string       `yaml:"include_server,omitempty" json:"include_server,omitempty"`
+	ExcludeServer string       `yaml:"exclude_server,omitempty" json:"exclude_server,omitempty"`
+	Rename        []RenameRule `yaml:"rename,omitempty" json:"rename,omitempty"`
+	Dedupe        bool         `yaml:"dedupe,omitempty" json:"dedupe,omitempty"`
+	CountryTag    string       `yaml:"country_tag,omitempty" json:"country_tag,omitempty"`
+}
+
+type RenameRule struct {
+	Pattern string `yaml:"pattern" json:"pattern"`
+	Replace string `yaml:"replace" json:"replace"`
+}